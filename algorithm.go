@@ -1,8 +1,18 @@
 package rectpack
 
-type packAlgorithm interface {
+import "slices"
+
+// Algorithm is the interface implemented by rectangle packing algorithms, which perform the actual
+// computation for a Packer. The built-in algorithms are created with NewAlgorithm, and custom
+// implementations can be used with NewAlgorithmPacker.
+//
+// Sizes are packed into cells that are enlarged by the padding on their right and bottom edges.
+// The cell of every packed rectangle must lie within the maximum extents, and must not overlap
+// the cell of any other packed rectangle or any reserved rectangle. The rectpacktest package
+// provides a test suite to verify that an implementation conforms to these requirements.
+type Algorithm interface {
 	// Reset returns the packer to its initial configured state with the specified maximum extents.
-	// This function will panic if width or height is less than 1.
+	// Reserved rectangles are retained. This function will panic if width or height is less than 1.
 	Reset(width, height int)
 	// Used computes the ratio of used surface area to the maximum possible area, in the range of
 	// 0.0 (empty) and 1.0 (perfectly packed with no waste).
@@ -11,9 +21,43 @@ type packAlgorithm interface {
 	// its configuration and current state. The padding argument specifies the amount of empty
 	// space that should be left between rectangles.
 	//
-	// Returns a slice of sizes that could not be packed.
+	// Packed rectangles retain the ID of their size. When a rectangle is flipped, its width and
+	// height are swapped and its Flipped field is set.
+	//
+	// Returns a slice of sizes that could not be packed. The sizes argument may be reordered
+	// and used as storage for the result, so its contents are undefined afterwards.
 	Insert(padding int, sizes ...Size) []Size
-	// Rects returns a slice of rectangles that have been packed.
+	// Remove deletes the first packed rectangle with the specified ID, returning its area to the
+	// free space so that it can be reused. The padding argument must be the same value that was
+	// used when the rectangle was inserted.
+	//
+	// Returns false if no rectangle with the ID is packed.
+	Remove(padding, id int) bool
+	// Place adds rectangles at their specified locations as if they had been packed, removing
+	// the area they occupy from the free space. The padding argument specifies the amount of
+	// empty space that should be left after the rectangles.
+	//
+	// Returns a slice of rectangles that could not be placed because they are outside of the
+	// maximum extents, or overlap a rectangle that is already packed.
+	Place(padding int, rects ...Rect) []Rect
+	// Reserve marks areas of the bin as occupied, so that no rectangles will be packed into them.
+	// Unlike placed rectangles, reserved rectangles are not padded, are not included in Rects,
+	// and are retained when the algorithm is reset. The padding argument must be the same value
+	// that was used when the packed rectangles were inserted.
+	//
+	// Returns a slice of rectangles that could not be reserved because they are outside of the
+	// maximum extents, or overlap the cell of a rectangle that is already packed or reserved.
+	Reserve(padding int, rects ...Rect) []Rect
+	// Unreserve deletes the first reserved rectangle with the same location and size as the
+	// specified rectangle, returning its area to the free space. The padding argument must be the
+	// same value that was used when the packed rectangles were inserted.
+	//
+	// Returns false if no such rectangle is reserved.
+	Unreserve(padding int, rect Rect) bool
+	// Reserved returns a slice of rectangles that have been reserved.
+	Reserved() []Rect
+	// Rects returns a slice of rectangles that have been packed, in the order they were packed.
+	// The Bin field of each rectangle may be modified by the caller.
 	Rects() []Rect
 	// AllowFlip indicates if rectangles can be flipped/rotated to provide better placement.
 	//
@@ -21,16 +65,36 @@ type packAlgorithm interface {
 	AllowFlip(enabled bool)
 	// MaxSize returns the maximum size the algorithm can pack into.
 	MaxSize() Size
-	// UsedArea returns the total area that is occupied.
+	// UsedArea returns the total area that is occupied, which is the area of the cells of the
+	// packed rectangles and the area of the reserved rectangles.
 	UsedArea() int
 }
 
 type algorithmBase struct {
 	packed    []Rect
+	reserved  []Rect
 	maxWidth  int
 	maxHeight int
 	usedArea  int
 	allowFlip bool
+	block     int
+	observer  Observer
+	// done stops packing once it is closed, and is only set while packing with a context.
+	done <-chan struct{}
+	// stopped indicates that packing was stopped by done before all sizes were tried.
+	stopped bool
+	// scoring is the ID of the size being scored, which is only tracked when there is an
+	// observer.
+	scoring int
+}
+
+// blockAligner is implemented by algorithms that support aligning rectangles to a grid of
+// blocks, which is all of the built-in algorithms.
+type blockAligner interface {
+	// setBlock sets the size of the blocks, where values of 1 or less disable alignment.
+	setBlock(size int)
+	// blockSize returns the size of the blocks.
+	blockSize() int
 }
 
 func (p *algorithmBase) Used() float64 {
@@ -42,12 +106,204 @@ func (p *algorithmBase) Reset(width, height int) {
 	p.maxHeight = height
 	p.usedArea = 0
 	p.packed = p.packed[:0]
+	for _, cell := range p.reservedCells() {
+		p.usedArea += cell.Area()
+	}
 }
 
 func (p *algorithmBase) Rects() []Rect {
 	return p.packed
 }
 
+func (p *algorithmBase) Reserved() []Rect {
+	return p.reserved
+}
+
+// reservedCells returns the area occupied by each reserved rectangle, limited to the maximum
+// extents. Rectangles that lie completely outside of the maximum extents are omitted.
+func (p *algorithmBase) reservedCells() []Rect {
+	cells := make([]Rect, 0, len(p.reserved))
+	for _, rect := range p.reserved {
+		if cell := p.cell(rect, 0); !cell.IsEmpty() {
+			cells = append(cells, cell)
+		}
+	}
+	return cells
+}
+
+// occupied returns the area occupied by each reserved and packed rectangle, limited to the
+// maximum extents.
+func (p *algorithmBase) occupied(padding int) []Rect {
+	cells := p.reservedCells()
+	for _, rect := range p.packed {
+		cells = append(cells, p.cell(rect, padding))
+	}
+	return cells
+}
+
+// remove deletes the first packed rectangle with the specified ID from the packed slice,
+// returning it. The used area is not modified.
+func (p *algorithmBase) remove(id int) (Rect, bool) {
+	index := slices.IndexFunc(p.packed, func(rect Rect) bool { return rect.ID == id })
+	if index < 0 {
+		return Rect{}, false
+	}
+
+	rect := p.packed[index]
+	p.packed = slices.Delete(p.packed, index, index+1)
+	return rect, true
+}
+
+// place appends each rectangle that lies within the maximum extents without its cell overlapping
+// that of any packed or reserved rectangle to the packed slice, calling fn with the area it
+// occupies. Returns the rectangles that could not be placed.
+func (p *algorithmBase) place(padding int, rects []Rect, fn func(cell Rect)) []Rect {
+	return p.add(&p.packed, padding, padding, rects, fn)
+}
+
+// reserve appends each rectangle that lies within the maximum extents without overlapping the
+// cell of any packed or reserved rectangle to the reserved slice, calling fn with the area it
+// occupies. The padding is that of the packed rectangles. Returns the rectangles that could not
+// be reserved.
+func (p *algorithmBase) reserve(padding int, rects []Rect, fn func(cell Rect)) []Rect {
+	return p.add(&p.reserved, 0, padding, rects, fn)
+}
+
+// unreserve deletes the first reserved rectangle with the same location and size as the
+// specified rectangle from the reserved slice, returning it. The used area is not modified.
+func (p *algorithmBase) unreserve(rect Rect) (Rect, bool) {
+	index := slices.IndexFunc(p.reserved, func(reserved Rect) bool { return reserved.Eq(rect) })
+	if index < 0 {
+		return Rect{}, false
+	}
+
+	reserved := p.reserved[index]
+	p.reserved = slices.Delete(p.reserved, index, index+1)
+	return reserved, true
+}
+
+// add implements place and reserve, appending to the specified slice. The cell of each rectangle
+// includes the specified padding, while those that are already occupied include the padding of
+// the packer.
+func (p *algorithmBase) add(dst *[]Rect, padding, packerPadding int, rects []Rect, fn func(cell Rect)) []Rect {
+	var failed []Rect
+	occupied := p.occupied(packerPadding)
+	for _, rect := range rects {
+		cell := p.cell(rect, padding)
+		if !p.canPlace(rect, cell, occupied) {
+			failed = append(failed, rect)
+			continue
+		}
+
+		*dst = append(*dst, rect)
+		occupied = append(occupied, cell)
+		p.usedArea += cell.Area()
+		if fn != nil {
+			fn(cell)
+		}
+	}
+	return failed
+}
+
+// canPlace tests whether a rectangle lies within the maximum extents without its cell overlapping
+// any of the occupied cells.
+func (p *algorithmBase) canPlace(rect, cell Rect, occupied []Rect) bool {
+	if rect.IsEmpty() || rect.X < 0 || rect.Y < 0 || rect.Right() > p.maxWidth || rect.Bottom() > p.maxHeight {
+		return false
+	}
+
+	for _, other := range occupied {
+		if other.Intersects(cell) {
+			return false
+		}
+	}
+	return true
+}
+
+// cell returns the area occupied by a packed rectangle, which includes the padding on its right
+// and bottom edges, limited to the maximum extents. When aligning to blocks, the area is extended
+// to include every block the rectangle touches. Padding beyond the maximum extents separates the
+// rectangle from nothing, so a placed rectangle may end at the edge of the bin.
+func (p *algorithmBase) cell(rect Rect, padding int) Rect {
+	p.padSize(&rect.Size, padding)
+	if p.block > 1 {
+		right, bottom := alignUp(rect.Right(), p.block), alignUp(rect.Bottom(), p.block)
+		rect.X -= rect.X % p.block
+		rect.Y -= rect.Y % p.block
+		rect.Width, rect.Height = right-rect.X, bottom-rect.Y
+	}
+	rect.Width = min(rect.Width, p.maxWidth-rect.X)
+	rect.Height = min(rect.Height, p.maxHeight-rect.Y)
+	return rect
+}
+
+// insertWaste packs the first of the sizes that fits into a waste map, which contains free areas
+// that the algorithm itself cannot reach, returning its index.
+func (p *algorithmBase) insertWaste(wasteMap *guillotinePack, padding int, sizes []Size) (int, bool) {
+	if wasteMap == nil || len(wasteMap.freeRects) == 0 {
+		return -1, false
+	}
+
+	for i, size := range sizes {
+		p.padSize(&size, padding)
+		if wasteMap.observer != nil {
+			wasteMap.scoring = size.ID
+		}
+
+		var index int
+		node := wasteMap.findPosition(size.Width, size.Height, p.options(size.Options), &index)
+		if node.Height == 0 {
+			continue
+		}
+
+		wasteMap.placeNode(index, node)
+		wasteMap.mergeFreeList()
+		p.usedArea += node.Area()
+
+		unpadRect(&node, sizes[i])
+		p.addPacked(node)
+		return i, true
+	}
+	return -1, false
+}
+
+// canFlip tests whether a size may be flipped, which requires flipping to be enabled for the
+// algorithm and not disabled for the size.
+func (p *algorithmBase) canFlip(opts SizeOptions) bool {
+	return p.allowFlip && !opts.NoFlip
+}
+
+// options returns the options used to fit a size, which combine its own alignment with the
+// alignment to blocks.
+func (p *algorithmBase) options(opts SizeOptions) SizeOptions {
+	if p.block > 1 {
+		opts.AlignX = lcm(max(opts.AlignX, 1), p.block)
+		opts.AlignY = lcm(max(opts.AlignY, 1), p.block)
+	}
+	return opts
+}
+
+// padSize adds the padding to a size, rounded up to the size of the blocks.
+func (p *algorithmBase) padSize(size *Size, padding int) {
+	padSize(size, padding, p.block)
+}
+
+// addPacked appends a rectangle that has been packed, notifying the observer if there is one.
+func (p *algorithmBase) addPacked(rect Rect) {
+	p.packed = append(p.packed, rect)
+	if p.observer != nil {
+		p.observer.Observe(Event{Kind: EventPlace, ID: rect.ID, Rect: rect})
+	}
+}
+
+func (p *algorithmBase) setBlock(size int) {
+	p.block = max(size, 0)
+}
+
+func (p *algorithmBase) blockSize() int {
+	return p.block
+}
+
 func (p *algorithmBase) AllowFlip(enabled bool) {
 	p.allowFlip = enabled
 }
@@ -67,32 +323,105 @@ func abs(x int) int {
 	return -x
 }
 
-func padSize(size *Size, padding int) {
-	if padding <= 0 {
-		return
+// padSize adds the padding to a size, using the padding of the size itself when it specifies one,
+// and then rounds it up to a multiple of the block size. Block sizes of 1 or less are ignored.
+func padSize(size *Size, padding, block int) {
+	if padding = size.Options.padding(padding); padding > 0 {
+		size.Width += padding
+		size.Height += padding
+	}
+	size.Width = alignUp(size.Width, block)
+	size.Height = alignUp(size.Height, block)
+}
+
+// unpadRect reverses padSize, restoring the original size of a packed rectangle along with its
+// ID and options. The rectangle is kept at the top-left of the area it occupies, leaving the
+// padding and rounding on the right and bottom.
+//
+// Rectangles on the left and top edges of the bin were once moved inward by the padding and
+// shrunk to compensate. That made their cell impossible to recover from the rectangle alone,
+// which Remove, Place, and Validate all require, and left them smaller than their size.
+func unpadRect(rect *Rect, size Size) {
+	rect.Width, rect.Height = size.Width, size.Height
+	if rect.Flipped {
+		rect.Width, rect.Height = size.Height, size.Width
 	}
-	size.Width += padding
-	size.Height += padding
+	rect.ID = size.ID
+	rect.Options = size.Options
 }
 
-func unpadRect(rect *Rect, padding int) {
-	if padding <= 0 {
-		return
+// padding returns the padding applied to a size, which is its own padding when specified, or
+// otherwise the padding of the packer.
+func (opts *SizeOptions) padding(padding int) int {
+	if opts.Padding > 0 {
+		return opts.Padding
 	}
+	return padding
+}
+
+// aligned tests whether the options restrict the location of a size.
+func (opts *SizeOptions) aligned() bool {
+	return opts.AlignX > 1 || opts.AlignY > 1
+}
 
-	if rect.X == 0 {
-		rect.X += padding
-		rect.Width -= padding * 2
-	} else {
-		rect.Width -= padding
+// alignUp rounds a value up to the nearest multiple of align. Values of align of 1 or less
+// leave the value unchanged.
+func alignUp(value, align int) int {
+	if align <= 1 {
+		return value
 	}
+	return (value + align - 1) / align * align
+}
+
+// lcm returns the least common multiple of two positive values.
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// alignFree returns the area of a free rectangle that a size can occupy when its location is
+// aligned, which is the free rectangle with its top-left corner rounded up to the alignment.
+func alignFree(free Rect, opts SizeOptions) Rect {
+	if !opts.aligned() {
+		return free
+	}
+	x, y := alignUp(free.X, opts.AlignX), alignUp(free.Y, opts.AlignY)
+	return NewRect(x, y, max(0, free.Right()-x), max(0, free.Bottom()-y))
+}
+
+// subtractRect removes the area of used from each of the rectangles, splitting those that
+// intersect into up to four smaller rectangles. When the input rectangles are disjoint, so are
+// the results.
+func subtractRect(rects []Rect, used Rect) []Rect {
+	result := make([]Rect, 0, len(rects)+4)
+	for _, rect := range rects {
+		if !rect.Intersects(used) {
+			result = append(result, rect)
+			continue
+		}
+
+		// Full-width areas above and below the used rectangle.
+		if used.Y > rect.Y {
+			result = append(result, NewRect(rect.X, rect.Y, rect.Width, used.Y-rect.Y))
+		}
+		if used.Bottom() < rect.Bottom() {
+			result = append(result, NewRect(rect.X, used.Bottom(), rect.Width, rect.Bottom()-used.Bottom()))
+		}
 
-	if rect.Y == 0 {
-		rect.Y += padding
-		rect.Height -= padding * 2
-	} else {
-		rect.Height -= padding
+		// Areas to the left and right, limited to the band the used rectangle spans.
+		top := max(rect.Y, used.Y)
+		bottom := min(rect.Bottom(), used.Bottom())
+		if used.X > rect.X {
+			result = append(result, NewRect(rect.X, top, used.X-rect.X, bottom-top))
+		}
+		if used.Right() < rect.Right() {
+			result = append(result, NewRect(used.Right(), top, rect.Right()-used.Right(), bottom-top))
+		}
 	}
+	return result
 }
 
 // vim: ts=4
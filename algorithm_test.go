@@ -0,0 +1,64 @@
+package rectpack_test
+
+import (
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/rectpacktest"
+)
+
+func TestAlgorithms(t *testing.T) {
+	heuristics := []rectpack.Heuristic{
+		rectpack.MaxRectsBSSF,
+		rectpack.MaxRectsBL,
+		rectpack.MaxRectsCP,
+		rectpack.SkylineBLF,
+		rectpack.SkylineMinWaste,
+		rectpack.GuillotineBAF,
+		rectpack.Guillotine | rectpack.WorstAreaFit | rectpack.SplitLongerAxis,
+		rectpack.ShelfNextFit,
+		rectpack.ShelfFirstFit,
+		rectpack.ShelfBestAreaFit,
+		rectpack.ShelfBestWidthFit,
+		rectpack.ShelfBestHeightFit,
+		rectpack.ShelfWorstWidthFit,
+		rectpack.ShelfFirstFit | rectpack.WasteMap,
+		rectpack.ShelfBestAreaFit | rectpack.WasteMap,
+		rectpack.BinaryTree,
+		rectpack.BinaryTreeGrow,
+	}
+
+	for _, heuristic := range heuristics {
+		t.Run(heuristic.String(), func(t *testing.T) {
+			rectpacktest.TestAlgorithm(t, func(width, height int) rectpack.Algorithm {
+				algo, err := rectpack.NewAlgorithm(width, height, heuristic)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return algo
+			})
+		})
+	}
+}
+
+// wrapped is a custom algorithm that delegates to a built-in one.
+type wrapped struct {
+	rectpack.Algorithm
+}
+
+func TestAlgorithmPacker(t *testing.T) {
+	algo, _ := rectpack.NewAlgorithm(128, 128, rectpack.MaxRectsBSSF)
+	packer := rectpack.NewAlgorithmPacker(wrapped{algo})
+	packer.Padding = 1
+	for i := 0; i < 16; i++ {
+		packer.InsertSize(i, 31, 31)
+	}
+	if !packer.Pack() || len(packer.Map()) != 16 || packer.Used(false) != 1 {
+		t.Error("custom algorithm did not pack all rectangles")
+	}
+	if _, err := packer.MarshalJSON(); err == nil {
+		t.Error("expected error when saving a custom algorithm")
+	}
+}
+
+// vim: ts=4
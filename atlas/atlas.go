@@ -0,0 +1,511 @@
+// Package atlas builds texture atlases by packing images with a rectpack.Packer or
+// rectpack.MultiPacker, and compositing them into one image for each bin.
+package atlas
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/format"
+)
+
+// Atlas contains the result of building a texture atlas.
+type Atlas struct {
+	// Pages contains the composited images, one for each bin that was packed. The images are
+	// not premultiplied, so that the colour of transparent pixels filled by bleeding is retained.
+	//
+	// Pages were previously of type *image.RGBA. Callers that require premultiplied images can
+	// convert a page with draw.Draw, at the cost of the colour of fully transparent pixels.
+	Pages []*image.NRGBA
+	// Sprites maps the name of each image to the rectangle it was drawn into. The Bin field of
+	// the rectangle is the index of the page it was drawn onto.
+	//
+	// Rectangles that are flipped contain the image rotated 90 degrees clockwise, or
+	// counter-clockwise when the CounterClockwise option of the builder is set.
+	Sprites map[string]rectpack.Rect
+	// Trims maps the name of each image that was trimmed to how it was trimmed. Images that were
+	// not trimmed are omitted.
+	Trims map[string]Trim
+	// Aliases maps the name of each image that was identical to another to the name of the image
+	// that was packed in its place. Both names are present in Sprites with the same rectangle.
+	Aliases map[string]string
+}
+
+// Trim describes the area of an image that remains after removing its transparent edges.
+type Trim struct {
+	// Source is the size of the original image.
+	Source rectpack.Size
+	// Offset is the location of the top-left corner of the trimmed area within the original
+	// image.
+	Offset rectpack.Point
+}
+
+// Metadata describes the atlas for use with the encoders of the format package. The images
+// argument contains the file name that each page will be saved as, in the same order as Pages.
+//
+// Sprites are sorted by name within each page.
+func (a *Atlas) Metadata(images []string) []format.Page {
+	pages := make([]format.Page, len(a.Pages))
+	for i, img := range a.Pages {
+		size := img.Bounds().Size()
+		pages[i].Size = rectpack.NewSize(size.X, size.Y)
+		if i < len(images) {
+			pages[i].Image = images[i]
+		}
+	}
+
+	names := make([]string, 0, len(a.Sprites))
+	for name := range a.Sprites {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		rect := a.Sprites[name]
+		sprite := format.Sprite{Name: name, Rect: rect}
+		if trim, ok := a.Trims[name]; ok {
+			sprite.Source = trim.Source
+			sprite.Offset = trim.Offset
+		}
+		pages[rect.Bin].Sprites = append(pages[rect.Bin].Sprites, sprite)
+	}
+	return pages
+}
+
+// FitError is returned by Build when not all images could be packed into the atlas.
+type FitError struct {
+	// Names contains the names of the images that could not be packed.
+	Names []string
+}
+
+// Error implements the error interface.
+func (e *FitError) Error() string {
+	return fmt.Sprintf("cannot fit %d image(s) into the atlas", len(e.Names))
+}
+
+// packer is the common interface of rectpack.Packer and rectpack.MultiPacker required to
+// build an atlas.
+type packer interface {
+	Alias(id, target int)
+	Clear()
+	Insert(sizes ...rectpack.Size) []rectpack.Size
+	Map() map[int]rectpack.Rect
+	Pack() bool
+}
+
+// Builder collects named images and composites them into an atlas.
+type Builder struct {
+	// packer is used to compute the location of each image.
+	packer packer
+	// pages returns the size of each page after packing.
+	pages func() []rectpack.Size
+	// names contains the name of each image, where the index is the ID used for packing.
+	names []string
+	// images contains the images to be packed, in the same order as names.
+	images []image.Image
+	// Extrude is the number of pixels that the border of each image is repeated outward on every
+	// side, which prevents seams from appearing at its edges under bilinear filtering. The space
+	// for the extruded pixels is reserved by the packer in addition to its padding, and is not
+	// included in the rectangles of the atlas.
+	//
+	// Default: 0
+	Extrude int
+	// Bleed indicates if fully transparent pixels are filled with the colour of the nearest pixel
+	// that is not, keeping their alpha at 0. This prevents dark fringes from appearing around
+	// transparent areas of images under bilinear filtering.
+	//
+	// Default: false
+	Bleed bool
+	// Trim indicates if the transparent edges of each image are removed before packing it, such
+	// that only the smallest area containing all of its visible pixels is packed. The size of the
+	// original image and the location of the trimmed area within it are recorded in the Trims of
+	// the atlas, allowing the original image to be reconstructed.
+	//
+	// Default: false
+	Trim bool
+	// TrimThreshold is the largest alpha value of pixels that are considered transparent when
+	// trimming, in the range of 0 to 255.
+	//
+	// Default: 0
+	TrimThreshold uint8
+	// Dedupe indicates if images with identical pixels are packed only once. The pixels are
+	// compared after trimming, so images that only differ by their transparent edges are also
+	// considered identical. Each duplicate is added to the packer as an alias of the image that
+	// was packed, and is recorded in the Aliases of the atlas.
+	//
+	// Default: false
+	Dedupe bool
+	// CounterClockwise indicates if images that are flipped are rotated 90 degrees
+	// counter-clockwise instead of clockwise, as expected by formats such as libGDX. See the
+	// CounterClockwise field of format.Format.
+	//
+	// Default: false
+	CounterClockwise bool
+}
+
+// NewBuilder creates a new builder that packs images using the specified packer, producing an
+// atlas with a single page.
+//
+// The configuration of the packer, such as its heuristics, padding, and flip setting, is used
+// as-is. Any state of the packer is cleared when the atlas is built.
+func NewBuilder(p *rectpack.Packer) *Builder {
+	return &Builder{
+		packer: p,
+		pages: func() []rectpack.Size {
+			return []rectpack.Size{p.Size()}
+		},
+	}
+}
+
+// NewMultiBuilder creates a new builder that packs images using the specified packer, producing
+// an atlas with a page for each bin.
+//
+// The configuration of the packer, such as its heuristics, padding, and flip setting, is used
+// as-is. Any state of the packer is cleared when the atlas is built.
+func NewMultiBuilder(p *rectpack.MultiPacker) *Builder {
+	return &Builder{
+		packer: p,
+		pages: func() []rectpack.Size {
+			bins := p.Bins()
+			sizes := make([]rectpack.Size, len(bins))
+			for i, bin := range bins {
+				sizes[i] = bin.Size()
+			}
+			return sizes
+		},
+	}
+}
+
+// Add adds an image with the specified name. If an image with the same name was already added,
+// it is replaced.
+func (b *Builder) Add(name string, img image.Image) {
+	if index := slices.Index(b.names, name); index >= 0 {
+		b.images[index] = img
+		return
+	}
+
+	b.names = append(b.names, name)
+	b.images = append(b.images, img)
+}
+
+// AddFile decodes the image file at the specified path, and adds it using the path as its name.
+//
+// The decoders for the desired image formats must be registered, typically by importing
+// packages such as image/png for their side-effects.
+func (b *Builder) AddFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	b.Add(path, img)
+	return nil
+}
+
+// AddFS decodes each image file in the file system that matches any of the patterns, and adds
+// it using its path within the file system as its name. The pattern syntax is the same as
+// fs.Glob.
+//
+// The decoders for the desired image formats must be registered, typically by importing
+// packages such as image/png for their side-effects.
+func (b *Builder) AddFS(fsys fs.FS, patterns ...string) error {
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range names {
+			file, err := fsys.Open(name)
+			if err != nil {
+				return err
+			}
+
+			img, _, err := image.Decode(file)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+
+			b.Add(name, img)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of images that have been added.
+func (b *Builder) Len() int {
+	return len(b.names)
+}
+
+// Build packs all images that have been added and composites them into an atlas.
+//
+// If not all images could be packed, a *FitError is returned along with an atlas containing
+// the images that were.
+func (b *Builder) Build() (*Atlas, error) {
+	b.packer.Clear()
+
+	// Reserve the space for extrusion by packing each image as if it were that much larger.
+	extrude := max(b.Extrude, 0)
+	sizes := make([]rectpack.Size, 0, len(b.images))
+	areas := make([]image.Rectangle, len(b.images))
+	aliases := make(map[int]int)
+	hashes := make(map[[sha256.Size]byte]int)
+	for i, img := range b.images {
+		areas[i] = img.Bounds()
+		if b.Trim {
+			areas[i] = trimBounds(img, b.TrimThreshold)
+		}
+		if b.Dedupe {
+			hash := hashPixels(img, areas[i])
+			if target, ok := hashes[hash]; ok {
+				aliases[i] = target
+				continue
+			}
+			hashes[hash] = i
+		}
+		sizes = append(sizes, rectpack.NewSizeID(i, areas[i].Dx()+2*extrude, areas[i].Dy()+2*extrude))
+	}
+	// Sizes are inserted one at a time, so that an online packer places them in the order the
+	// images were added instead of choosing the best of all of them at once.
+	for _, size := range sizes {
+		b.packer.Insert(size)
+	}
+	b.packer.Pack()
+	for id, target := range aliases {
+		b.packer.Alias(id, target)
+	}
+
+	result := &Atlas{
+		Sprites: make(map[string]rectpack.Rect, len(b.names)),
+		Trims:   make(map[string]Trim),
+		Aliases: make(map[string]string),
+	}
+	for _, size := range b.pages() {
+		result.Pages = append(result.Pages, image.NewNRGBA(image.Rect(0, 0, size.Width, size.Height)))
+	}
+
+	mapping := b.packer.Map()
+	for i, name := range b.names {
+		rect, ok := mapping[i]
+		if !ok {
+			continue
+		}
+		rect.X += extrude
+		rect.Y += extrude
+		rect.Width -= 2 * extrude
+		rect.Height -= 2 * extrude
+		result.Sprites[name] = rect
+
+		if target, ok := aliases[i]; ok {
+			result.Aliases[name] = b.names[target]
+		} else {
+			drawSprite(result.Pages[rect.Bin], rect, b.images[i], areas[i], b.CounterClockwise)
+			extrudeSprite(result.Pages[rect.Bin], rect, extrude)
+		}
+
+		if bounds := b.images[i].Bounds(); areas[i] != bounds {
+			result.Trims[name] = Trim{
+				Source: rectpack.NewSize(bounds.Dx(), bounds.Dy()),
+				Offset: rectpack.NewPoint(areas[i].Min.X-bounds.Min.X, areas[i].Min.Y-bounds.Min.Y),
+			}
+		}
+	}
+
+	if b.Bleed {
+		for _, page := range result.Pages {
+			bleed(page)
+		}
+	}
+
+	if len(result.Sprites) < len(b.names) {
+		var missing []string
+		for _, name := range b.names {
+			if _, ok := result.Sprites[name]; !ok {
+				missing = append(missing, name)
+			}
+		}
+		return result, &FitError{Names: missing}
+	}
+	return result, nil
+}
+
+// drawSprite draws an area of the image into the area of the rectangle, rotating it 90 degrees
+// clockwise if the rectangle is flipped, or counter-clockwise if ccw is set.
+func drawSprite(dst *image.NRGBA, rect rectpack.Rect, src image.Image, area image.Rectangle, ccw bool) {
+	bounds := image.Rect(rect.X, rect.Y, rect.Right(), rect.Bottom())
+	if !rect.Flipped {
+		draw.Draw(dst, bounds, src, area.Min, draw.Src)
+		return
+	}
+
+	// Normalize the source so its pixels can be copied directly.
+	size := area.Size()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+	draw.Draw(nrgba, nrgba.Bounds(), src, area.Min, draw.Src)
+
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			s := nrgba.PixOffset(x, y)
+			d := dst.PixOffset(rect.X+size.Y-1-y, rect.Y+x)
+			if ccw {
+				d = dst.PixOffset(rect.X+y, rect.Y+size.X-1-x)
+			}
+			copy(dst.Pix[d:d+4], nrgba.Pix[s:s+4])
+		}
+	}
+}
+
+// hashPixels returns a hash of the size and non-premultiplied pixels of an area of the image, such
+// that areas with identical pixels have the same hash regardless of the type of image.
+func hashPixels(img image.Image, area image.Rectangle) [sha256.Size]byte {
+	nrgba := image.NewNRGBA(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, area.Min, draw.Src)
+
+	hash := sha256.New()
+	binary.Write(hash, binary.LittleEndian, [2]int32{int32(area.Dx()), int32(area.Dy())})
+	hash.Write(nrgba.Pix)
+
+	var sum [sha256.Size]byte
+	hash.Sum(sum[:0])
+	return sum
+}
+
+// trimBounds returns the smallest area of the image that contains every pixel with an alpha value
+// greater than the threshold. Images without any such pixels are trimmed to their top-left pixel,
+// as an empty area cannot be packed.
+func trimBounds(img image.Image, threshold uint8) image.Rectangle {
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return bounds
+	}
+
+	limit := uint32(threshold) * 0x101
+	area := image.Rectangle{Min: bounds.Max, Max: bounds.Min}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > limit {
+				area.Min.X, area.Min.Y = min(area.Min.X, x), min(area.Min.Y, y)
+				area.Max.X, area.Max.Y = max(area.Max.X, x+1), max(area.Max.Y, y+1)
+			}
+		}
+	}
+
+	if area.Empty() {
+		return image.Rectangle{Min: bounds.Min, Max: bounds.Min.Add(image.Pt(1, 1))}
+	}
+	return area
+}
+
+// Hull returns the convex hull of the pixels of the image with an alpha value greater than the
+// threshold, which is suitable for packing with a rectpack.PolygonPacker. The vertices lie on the
+// corners of the pixels, relative to the top-left corner of the image bounds. Returns nil if the
+// image has no such pixels.
+func Hull(img image.Image, threshold uint8) []rectpack.Point {
+	bounds := img.Bounds()
+	limit := uint32(threshold) * 0x101
+
+	// Only the outer corners of the first and last visible pixels of each row can be on the hull.
+	var points []rectpack.Point
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		left, right := -1, -1
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > limit {
+				if left < 0 {
+					left = x
+				}
+				right = x
+			}
+		}
+		if left < 0 {
+			continue
+		}
+
+		top, bottom := y-bounds.Min.Y, y-bounds.Min.Y+1
+		left, right = left-bounds.Min.X, right-bounds.Min.X+1
+		points = append(points,
+			rectpack.NewPoint(left, top), rectpack.NewPoint(left, bottom),
+			rectpack.NewPoint(right, top), rectpack.NewPoint(right, bottom))
+	}
+
+	if len(points) == 0 {
+		return nil
+	}
+	return rectpack.ConvexHull(points)
+}
+
+// extrudeSprite repeats the pixels on the border of a drawn sprite outward by the specified
+// amount, with the corner pixels filling the corners. As the sprite has already been drawn, the
+// border is that of its rotated image when the rectangle is flipped.
+func extrudeSprite(dst *image.NRGBA, rect rectpack.Rect, amount int) {
+	if amount <= 0 || rect.IsEmpty() {
+		return
+	}
+
+	inner := image.Rect(rect.X, rect.Y, rect.Right(), rect.Bottom())
+	outer := inner.Inset(-amount).Intersect(dst.Bounds())
+	for y := outer.Min.Y; y < outer.Max.Y; y++ {
+		sy := min(max(y, inner.Min.Y), inner.Max.Y-1)
+		for x := outer.Min.X; x < outer.Max.X; x++ {
+			if image.Pt(x, y).In(inner) {
+				continue
+			}
+			sx := min(max(x, inner.Min.X), inner.Max.X-1)
+			dst.SetNRGBA(x, y, dst.NRGBAAt(sx, sy))
+		}
+	}
+}
+
+// bleed fills each fully transparent pixel with the colour of the nearest pixel that is not,
+// leaving its alpha at 0. Distances are measured in steps to any of the eight neighbouring
+// pixels, and images without any visible pixels are unchanged.
+func bleed(dst *image.NRGBA) {
+	bounds := dst.Bounds()
+	filled := make([]bool, bounds.Dx()*bounds.Dy())
+	index := func(x, y int) int {
+		return (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+	}
+
+	// Fill outward from every visible pixel at once, so that each transparent pixel receives the
+	// colour of whichever is closest.
+	var queue []image.Point
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if dst.NRGBAAt(x, y).A != 0 {
+				filled[index(x, y)] = true
+				queue = append(queue, image.Pt(x, y))
+			}
+		}
+	}
+
+	for i := 0; i < len(queue); i++ {
+		c := dst.NRGBAAt(queue[i].X, queue[i].Y)
+		c.A = 0
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				next := queue[i].Add(image.Pt(dx, dy))
+				if !next.In(bounds) || filled[index(next.X, next.Y)] {
+					continue
+				}
+				filled[index(next.X, next.Y)] = true
+				dst.SetNRGBA(next.X, next.Y, c)
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,288 @@
+package atlas
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// gradient creates an image where every pixel has a unique color derived from its location.
+func gradient(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(width), A: 255})
+		}
+	}
+	return img
+}
+
+// checkSprite tests that the image was drawn into the area of the rectangle on the page,
+// accounting for rotation in the specified direction when it is flipped.
+func checkSprite(t *testing.T, page *image.NRGBA, rect rectpack.Rect, src *image.NRGBA, ccw bool) {
+	size := src.Bounds().Size()
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			dx, dy := rect.X+x, rect.Y+y
+			if rect.Flipped && ccw {
+				dx, dy = rect.X+y, rect.Y+size.X-1-x
+			} else if rect.Flipped {
+				dx, dy = rect.X+size.Y-1-y, rect.Y+x
+			}
+			if got, want := page.NRGBAAt(dx, dy), src.NRGBAAt(x, y); got != want {
+				t.Fatalf("%s: pixel <%d, %d> is %v, expected %v", rect.String(), x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestBuild(t *testing.T) {
+	packer, _ := rectpack.NewPacker(256, 256, rectpack.MaxRectsBAF)
+	packer.Padding = 1
+	packer.AllowFlip(true)
+
+	builder := NewBuilder(packer)
+	images := make(map[string]*image.NRGBA)
+	for i := 0; i < 32; i++ {
+		name := fmt.Sprintf("sprite%d", i)
+		images[name] = gradient(8+i, 40-i)
+		builder.Add(name, images[name])
+	}
+
+	for _, ccw := range []bool{false, true} {
+		builder.CounterClockwise = ccw
+		atlas, err := builder.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(atlas.Pages) != 1 || len(atlas.Sprites) != len(images) {
+			t.Fatalf("expected 1 page and %d sprites, got %d and %d", len(images), len(atlas.Pages), len(atlas.Sprites))
+		}
+
+		flipped := 0
+		for name, rect := range atlas.Sprites {
+			checkSprite(t, atlas.Pages[0], rect, images[name], ccw)
+			if rect.Flipped {
+				flipped++
+			}
+		}
+		if flipped == 0 {
+			t.Errorf("expected some sprites to be flipped")
+		}
+	}
+}
+
+func TestBuildMulti(t *testing.T) {
+	packer, _ := rectpack.NewMultiPacker(64, 64, rectpack.SkylineBLF)
+	packer.MaxBins = 2
+
+	builder := NewMultiBuilder(packer)
+	images := make(map[string]*image.NRGBA)
+	for i := 0; i < 9; i++ {
+		name := fmt.Sprintf("sprite%d", i)
+		images[name] = gradient(32, 32)
+		builder.Add(name, images[name])
+	}
+
+	// Only 8 of the images fit into two pages.
+	atlas, err := builder.Build()
+	var fitErr *FitError
+	if !errors.As(err, &fitErr) || len(fitErr.Names) != 1 {
+		t.Fatalf("expected a FitError with 1 name, got %v", err)
+	}
+	if len(atlas.Pages) != 2 || len(atlas.Sprites) != 8 {
+		t.Fatalf("expected 2 pages and 8 sprites, got %d and %d", len(atlas.Pages), len(atlas.Sprites))
+	}
+
+	for name, rect := range atlas.Sprites {
+		checkSprite(t, atlas.Pages[rect.Bin], rect, images[name], false)
+	}
+}
+
+func TestExtrude(t *testing.T) {
+	const extrude = 2
+	packer, _ := rectpack.NewPacker(256, 256, rectpack.MaxRectsBSSF)
+	packer.Padding = 1
+	packer.AllowFlip(true)
+
+	builder := NewBuilder(packer)
+	builder.Extrude = extrude
+	builder.Bleed = true
+	images := make(map[string]*image.NRGBA)
+	for i := 0; i < 16; i++ {
+		name := fmt.Sprintf("sprite%d", i)
+		images[name] = gradient(4+i, 30-i)
+		// Clear the center of each image to test bleeding.
+		images[name].SetNRGBA(2, 2, color.NRGBA{})
+		builder.Add(name, images[name])
+	}
+
+	atlas, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page := atlas.Pages[0]
+	for name, rect := range atlas.Sprites {
+		src := images[name]
+		size := src.Bounds().Size()
+		if rect.Flipped {
+			size.X, size.Y = size.Y, size.X
+		}
+		if rect.Width != size.X || rect.Height != size.Y {
+			t.Fatalf("%s: expected size %v, got %s", name, size, rect.String())
+		}
+
+		// The cleared pixel is filled by bleeding from a neighbour, which all share the same blue
+		// component. Clear it again before comparing the rest.
+		cx, cy := rect.X+2, rect.Y+2
+		if rect.Flipped {
+			cx = rect.X + src.Bounds().Dy() - 3
+		}
+		if got := page.NRGBAAt(cx, cy); got.A != 0 || got.B != uint8(src.Bounds().Dx()) {
+			t.Errorf("%s: transparent pixel was not bled, got %v", name, got)
+		}
+		page.SetNRGBA(cx, cy, color.NRGBA{})
+		checkSprite(t, page, rect, src, false)
+
+		// Each pixel around the sprite repeats the nearest pixel on its border.
+		for y := rect.Y - extrude; y < rect.Bottom()+extrude; y++ {
+			for x := rect.X - extrude; x < rect.Right()+extrude; x++ {
+				sx := min(max(x, rect.X), rect.Right()-1)
+				sy := min(max(y, rect.Y), rect.Bottom()-1)
+				if got, want := page.NRGBAAt(x, y), page.NRGBAAt(sx, sy); got != want {
+					t.Fatalf("%s: pixel <%d, %d> is %v, expected %v", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	packer, _ := rectpack.NewPacker(256, 256, rectpack.SkylineBLF)
+	packer.AllowFlip(true)
+
+	builder := NewBuilder(packer)
+	builder.Trim = true
+	builder.TrimThreshold = 16
+
+	// A gradient surrounded by a transparent border, with faint pixels below the threshold.
+	padded := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	src := gradient(20, 10)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			padded.SetNRGBA(x+5, y+7, src.NRGBAAt(x, y))
+		}
+	}
+	padded.SetNRGBA(0, 0, color.NRGBA{A: 16})
+	builder.Add("padded", padded)
+	builder.Add("plain", gradient(8, 8))
+	builder.Add("empty", image.NewNRGBA(image.Rect(0, 0, 4, 4)))
+
+	atlas, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rect := atlas.Sprites["padded"]
+	checkSprite(t, atlas.Pages[0], rect, src, false)
+	trim, ok := atlas.Trims["padded"]
+	if !ok || trim.Source != rectpack.NewSize(40, 30) || trim.Offset != rectpack.NewPoint(5, 7) {
+		t.Errorf("expected trim from 40x30 at <5, 7>, got %+v", trim)
+	}
+	if _, ok := atlas.Trims["plain"]; ok {
+		t.Error("image without transparent edges was trimmed")
+	}
+	if rect := atlas.Sprites["empty"]; rect.Width != 1 || rect.Height != 1 {
+		t.Errorf("expected transparent image to be trimmed to 1x1, got %s", rect.String())
+	}
+
+	for _, page := range atlas.Metadata(nil) {
+		for _, sprite := range page.Sprites {
+			if sprite.Name == "padded" && (!sprite.Trimmed() || sprite.SourceSize() != trim.Source) {
+				t.Errorf("trim not reflected in metadata of %s", sprite.Name)
+			}
+		}
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	packer, _ := rectpack.NewMultiPacker(64, 64, rectpack.MaxRectsBSSF)
+
+	builder := NewMultiBuilder(packer)
+	builder.Trim = true
+	builder.Dedupe = true
+
+	// The second frame only differs by its transparent edges, so it is identical after trimming.
+	frame := gradient(16, 16)
+	shifted := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(shifted, image.Rect(2, 2, 18, 18), frame, image.Point{}, draw.Src)
+	builder.Add("frame0", frame)
+	builder.Add("frame1", shifted)
+	builder.Add("frame2", frame)
+	builder.Add("other", gradient(16, 17))
+
+	atlas, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packer.Rects()) != 2 {
+		t.Fatalf("expected 2 packed rectangles, got %d", len(packer.Rects()))
+	}
+
+	for _, name := range []string{"frame1", "frame2"} {
+		if atlas.Aliases[name] != "frame0" {
+			t.Errorf("expected %s to be an alias of frame0, got %q", name, atlas.Aliases[name])
+		}
+		if atlas.Sprites[name] != atlas.Sprites["frame0"] {
+			t.Errorf("%s does not have the same rectangle as frame0", name)
+		}
+	}
+	if trim := atlas.Trims["frame1"]; trim.Offset != rectpack.NewPoint(2, 2) {
+		t.Errorf("expected frame1 to keep its own trim offset, got %v", trim.Offset)
+	}
+
+	mapping := packer.Map()
+	if len(mapping) != 4 || mapping[1] != mapping[0] || mapping[2] != mapping[0] {
+		t.Errorf("expected packer to map aliases to the same rectangle, got %v", mapping)
+	}
+}
+
+func TestHull(t *testing.T) {
+	// A diamond with its corners cut off at the edges of the image.
+	img := image.NewNRGBA(image.Rect(10, 10, 20, 20))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			if abs(x*2-9)+abs(y*2-9) <= 10 {
+				img.SetNRGBA(x+10, y+10, color.NRGBA{A: 255})
+			}
+		}
+	}
+
+	hull := Hull(img, 0)
+	polygon := rectpack.NewPolygon(0, hull...)
+	bounds := polygon.Bounds()
+	if bounds != rectpack.NewRect(0, 0, 10, 10) {
+		t.Errorf("expected hull bounds of 10x10 at the origin, got %s", bounds.String())
+	}
+	if area := polygon.Area(); area >= 100 || area < 50 {
+		t.Errorf("expected hull to exclude the transparent corners, got area %v", area)
+	}
+	if Hull(image.NewNRGBA(image.Rect(0, 0, 4, 4)), 0) != nil {
+		t.Error("expected no hull for a transparent image")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// vim: ts=4
@@ -0,0 +1,227 @@
+package rectpack
+
+import (
+	"math"
+	"slices"
+	"sort"
+)
+
+// SizeConstraint describes the restrictions placed on the dimensions chosen by PackAuto.
+type SizeConstraint struct {
+	// MaxWidth is the largest width that can be chosen. Values of 0 or less indicate that
+	// DefaultSize will be used.
+	MaxWidth int
+	// MaxHeight is the largest height that can be chosen. Values of 0 or less indicate that
+	// DefaultSize will be used.
+	MaxHeight int
+	// PowerOfTwo restricts the width and height to be a power of two.
+	PowerOfTwo bool
+	// Square restricts the width and height to be equal.
+	Square bool
+	// Multiple restricts the width and height to be a multiple of the value. Values of 1 or
+	// less have no effect.
+	Multiple int
+	// Aspect is the preferred ratio between the width and height. When multiple sizes with
+	// the same area are able to contain all rectangles, the one closest to this ratio is
+	// chosen. Values of 0 or less indicate a preference for square sizes.
+	Aspect float64
+}
+
+// candidates returns all values within the given inclusive range that satisfy the constraints
+// in ascending order.
+func (c *SizeConstraint) candidates(lo, hi int) []int {
+	var values []int
+	step := max(1, c.Multiple)
+	lo = max(1, lo)
+
+	if c.PowerOfTwo {
+		for v := 1; v <= hi; v <<= 1 {
+			if v >= lo && v%step == 0 {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+
+	for v := (lo + step - 1) / step * step; v <= hi; v += step {
+		values = append(values, v)
+	}
+	return values
+}
+
+// PackAuto searches for the smallest size that is able to contain all staged and packed
+// rectangles while satisfying the constraints, and packs them into it. The packer's maximum
+// size is changed to the chosen size, which is returned.
+//
+// Every permitted width is tried, along with the shortest height the algorithm's layout needs
+// at that width. The result is the smallest size the algorithm can fill, which is not always
+// the smallest size that could theoretically contain the rectangles.
+//
+// The return value indicates if all rectangles were successfully packed. When false, no size
+// within the constraints could contain everything. The packer is then left with the largest
+// possible size, and Unpacked can be used to retrieve the sizes that failed.
+func (p *Packer) PackAuto(constraint SizeConstraint) (Size, bool) {
+	maxWidth := constraint.MaxWidth
+	if maxWidth <= 0 {
+		maxWidth = DefaultSize
+	}
+	maxHeight := constraint.MaxHeight
+	if maxHeight <= 0 {
+		maxHeight = DefaultSize
+	}
+
+	p.collect()
+	sortSizes(p.unpacked, p.sortFunc, p.sortRev)
+
+	// Compute the lower bounds that any candidate size must satisfy, including the padding of
+	// each size, which may override that of the packer. The minimum side is used for the extents
+	// as the rectangle may be flipped.
+	block := p.blockSize()
+	var area, minSide int
+	for _, size := range p.unpacked {
+		padSize(&size, p.Padding, block)
+		area += size.Area()
+		minSide = max(minSide, size.MinSide())
+	}
+
+	// Reserved rectangles cannot move, so the size must also contain them.
+	minWidth, minHeight := minSide, minSide
+	for _, rect := range p.algo.Reserved() {
+		area += rect.Area()
+		minWidth = max(minWidth, rect.Right())
+		minHeight = max(minHeight, rect.Bottom())
+	}
+
+	// Every candidate must consist of whole blocks.
+	if block > 1 {
+		constraint.Multiple = lcm(max(constraint.Multiple, 1), block)
+	}
+
+	widths := constraint.candidates(minWidth, maxWidth)
+	heights := constraint.candidates(minHeight, maxHeight)
+	if constraint.Square {
+		widths = slices.DeleteFunc(widths, func(w int) bool {
+			_, found := slices.BinarySearch(heights, w)
+			return !found
+		})
+		heights = widths
+	}
+
+	var best Size
+	if len(widths) > 0 && len(heights) > 0 {
+		if constraint.Square {
+			best = p.searchSquare(widths, area)
+		} else {
+			best = p.searchSize(widths, heights, area, constraint.Aspect)
+		}
+	}
+
+	if best.Width == 0 {
+		// Nothing fits, so pack as much as possible into the largest permitted size.
+		best = NewSize(maxWidth, maxHeight)
+		if len(widths) > 0 && len(heights) > 0 {
+			best = NewSize(widths[len(widths)-1], heights[len(heights)-1])
+		}
+	}
+
+	// The staged sizes are already sorted, so insert them directly instead of calling Pack. This
+	// guarantees the same ordering that was tested is used.
+	p.algo.Reset(best.Width, best.Height)
+	p.unpacked = p.algo.Insert(p.Padding, p.unpacked...)
+	return best, len(p.unpacked) == 0
+}
+
+// searchSquare returns the smallest square size that can contain the staged sizes, or an
+// empty size if there is none.
+func (p *Packer) searchSquare(sides []int, area int) Size {
+	start, _ := slices.BinarySearchFunc(sides, area, func(side, target int) int {
+		if side*side < target {
+			return -1
+		}
+		return 1
+	})
+
+	// The first side that is large enough is rarely far beyond the bound, so test them in order.
+	for _, side := range sides[start:] {
+		if p.fits(side, side) {
+			return NewSize(side, side)
+		}
+	}
+	return Size{}
+}
+
+// searchSize returns the size with the smallest area that can contain the staged sizes,
+// preferring the one closest to the given aspect ratio when areas are equal. An empty size is
+// returned if there is none.
+func (p *Packer) searchSize(widths, heights []int, area int, aspect float64) Size {
+	if aspect <= 0 {
+		aspect = 1.0
+	}
+
+	var best Size
+	bestArea := math.MaxInt
+	bestAspect := math.Inf(1)
+
+	for _, width := range widths {
+		// Skip heights that cannot contain the total area, or that cannot improve on the best.
+		start, _ := slices.BinarySearchFunc(heights, area, func(height, target int) int {
+			if width*height < target {
+				return -1
+			}
+			return 1
+		})
+		if start >= len(heights) || width*heights[start] > bestArea {
+			continue
+		}
+
+		// Pack into the tallest permitted height to learn the height the layout needs, which is
+		// then confirmed, searching the taller heights only when it is not enough.
+		needed, ok := p.height(width, heights[len(heights)-1])
+		if !ok {
+			continue
+		}
+		start += sort.SearchInts(heights[start:], needed)
+		if width*heights[start] > bestArea {
+			continue
+		}
+		index := start + sort.Search(len(heights)-start, func(i int) bool {
+			return p.fits(width, heights[start+i])
+		})
+		if index >= len(heights) {
+			continue
+		}
+
+		size := NewSize(width, heights[index])
+		ratio := math.Abs(size.Ratio() - aspect)
+		if size.Area() < bestArea || (size.Area() == bestArea && ratio < bestAspect) {
+			best = size
+			bestArea = size.Area()
+			bestAspect = ratio
+		}
+	}
+
+	return best
+}
+
+// height packs all staged sizes within the given size and returns the height of the area they
+// occupy, or false if they do not all fit. The packer's algorithm is reset in the process.
+func (p *Packer) height(width, height int) (int, bool) {
+	if !p.fits(width, height) {
+		return 0, false
+	}
+	var bottom int
+	for _, rect := range p.algo.Rects() {
+		bottom = max(bottom, rect.Bottom())
+	}
+	return bottom, true
+}
+
+// fits tests whether all staged sizes can be packed within the given size. The packer's
+// algorithm is reset in the process.
+func (p *Packer) fits(width, height int) bool {
+	sizes := slices.Clone(p.unpacked)
+	p.algo.Reset(width, height)
+	return len(p.algo.Insert(p.Padding, sizes...)) == 0
+}
+
+// vim: ts=4
@@ -0,0 +1,50 @@
+package rectpack_test
+
+import (
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/rectpacktest"
+)
+
+// BenchmarkPack packs each of the standard datasets with each preset heuristic, reporting the
+// time per insert, the number of bins, and the occupancy alongside the time per pack. Use the
+// cmd/rectbench command to compare sort functions as well.
+func BenchmarkPack(b *testing.B) {
+	for _, dataset := range rectpacktest.Datasets {
+		sizes := dataset.Sizes(1)
+		for _, heuristic := range rectpacktest.Heuristics {
+			b.Run(dataset.Name+"/"+heuristic.String(), func(b *testing.B) {
+				var result rectpacktest.Result
+				for i := 0; i < b.N; i++ {
+					result, _ = rectpacktest.Measure(sizes, 1024, 1024, heuristic, "area", true)
+				}
+				b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*len(sizes)), "ns/insert")
+				b.ReportMetric(float64(result.Bins), "bins")
+				b.ReportMetric(result.Occupancy, "occupancy")
+			})
+		}
+	}
+}
+
+// BenchmarkInsertOnline measures the time to insert a single size into a partially filled bin,
+// which is the cost of packing in online mode.
+func BenchmarkInsertOnline(b *testing.B) {
+	sizes := rectpacktest.Datasets[0].Sizes(1)
+	for _, heuristic := range rectpacktest.Heuristics {
+		b.Run(heuristic.String(), func(b *testing.B) {
+			var packer *rectpack.Packer
+			for i := 0; i < b.N; i++ {
+				if i%len(sizes) == 0 {
+					b.StopTimer()
+					packer, _ = rectpack.NewPacker(2048, 2048, heuristic)
+					packer.Online = true
+					b.StartTimer()
+				}
+				packer.Insert(sizes[i%len(sizes)])
+			}
+		})
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,142 @@
+// Command rectbench compares the quality and speed of heuristics and sort functions by packing the
+// standard datasets of the rectpacktest package into bins, printing a report with the number of
+// bins, the occupancy, and the time per insert of each combination.
+//
+// Usage:
+//
+//	rectbench [flags]
+//
+// The datasets are generated from a fixed seed, so the bin counts and occupancy are the same on
+// every run and can be compared between versions. Timings are the fastest of several runs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/rectpacktest"
+)
+
+const (
+	// exitError indicates that a combination could not be measured.
+	exitError = 1
+	// exitUsage indicates invalid flags or arguments.
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command with the given arguments, returning the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rectbench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var defaultHeuristics []string
+	for _, heuristic := range rectpacktest.Heuristics {
+		defaultHeuristics = append(defaultHeuristics, heuristic.String())
+	}
+	var defaultDatasets []string
+	for _, dataset := range rectpacktest.Datasets {
+		defaultDatasets = append(defaultDatasets, dataset.Name)
+	}
+
+	seed := flags.Int64("seed", 1, "seed used to generate the datasets")
+	width := flags.Int("width", 1024, "maximum width of each bin")
+	height := flags.Int("height", 1024, "maximum height of each bin")
+	allowFlip := flags.Bool("allow-flip", true, "allow rectangles to be rotated for a better fit")
+	runs := flags.Int("runs", 3, "number of times each combination is packed, reporting the fastest")
+	datasetNames := flags.String("datasets", strings.Join(defaultDatasets, ","), "comma-separated `names` of the datasets")
+	heuristicNames := flags.String("heuristics", strings.Join(defaultHeuristics, ","), "comma-separated `heuristics` to compare")
+	sortNames := flags.String("sort", "area,perimeter,diff,minside,maxside,ratio,none", "comma-separated sort `functions` to compare")
+	csv := flags.Bool("csv", false, "print the report as comma-separated values")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 || *runs < 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var datasets []rectpacktest.Dataset
+	for _, name := range strings.Split(*datasetNames, ",") {
+		index := -1
+		for i := range rectpacktest.Datasets {
+			if rectpacktest.Datasets[i].Name == name {
+				index = i
+			}
+		}
+		if index < 0 {
+			fmt.Fprintf(stderr, "unknown dataset %q\n", name)
+			return exitUsage
+		}
+		datasets = append(datasets, rectpacktest.Datasets[index])
+	}
+
+	var heuristics []rectpack.Heuristic
+	for _, name := range strings.Split(*heuristicNames, ",") {
+		heuristic, err := rectpack.ParseHeuristic(name)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		heuristics = append(heuristics, heuristic)
+	}
+
+	sorts := strings.Split(*sortNames, ",")
+	for _, name := range sorts {
+		if _, err := rectpack.ParseSortFunc(name); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+
+	// Rows end with a separator in a table so that the last column is aligned too.
+	var w io.Writer = stdout
+	table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	sep, end := ",", "\n"
+	if !*csv {
+		w, sep, end = table, "\t", "\t\n"
+	}
+	row := func(values ...any) {
+		for i, value := range values {
+			if i > 0 {
+				io.WriteString(w, sep)
+			}
+			fmt.Fprint(w, value)
+		}
+		io.WriteString(w, end)
+	}
+
+	row("dataset", "heuristic", "sort", "packed", "bins", "occupancy", "ns/insert")
+	for _, dataset := range datasets {
+		sizes := dataset.Sizes(*seed)
+		for _, heuristic := range heuristics {
+			for _, sort := range sorts {
+				var best rectpacktest.Result
+				for i := 0; i < *runs; i++ {
+					result, err := rectpacktest.Measure(sizes, *width, *height, heuristic, sort, *allowFlip)
+					if err != nil {
+						fmt.Fprintln(stderr, err)
+						return exitError
+					}
+					if i == 0 || result.Elapsed < best.Elapsed {
+						best = result
+					}
+				}
+				row(dataset.Name, heuristic.String(), sort, best.Count, best.Bins, fmt.Sprintf("%.4f", best.Occupancy), best.PerInsert().Nanoseconds())
+			}
+		}
+	}
+	table.Flush()
+	return 0
+}
+
+// vim: ts=4
@@ -0,0 +1,227 @@
+// Command rectpack packs images into texture atlases, writing each page as a PNG image along
+// with a metadata file describing the location of each image.
+//
+// Usage:
+//
+//	rectpack [flags] pattern...
+//
+// Each pattern is a glob matching the image files to pack. The metadata format is selected with
+// the -format flag, which accepts any of the names in format.Formats.
+//
+// Images are packed in the order the patterns match them when -online is set, and are otherwise
+// sorted by the -sort function first.
+//
+// The exit code is 0 on success, 1 when an error occurs, 2 for invalid usage, 3 when some images
+// did not fit into the atlas, and 4 when -validate finds a problem with the layout. When images
+// did not fit, those that did are still written. When the layout is invalid, nothing is written.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+
+	_ "image/gif"
+	_ "image/jpeg"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/atlas"
+	"github.com/ForeverZer0/rectpack/format"
+)
+
+const (
+	// exitError indicates an I/O or decoding error.
+	exitError = 1
+	// exitUsage indicates invalid flags or arguments.
+	exitUsage = 2
+	// exitNoFit indicates that some images did not fit into the atlas.
+	exitNoFit = 3
+	// exitInvalid indicates that the layout of the atlas failed validation.
+	exitInvalid = 4
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run executes the command with the given arguments, returning the exit code.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("rectpack", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: rectpack [flags] pattern...")
+		flags.PrintDefaults()
+	}
+
+	out := flags.String("out", "atlas", "base `path` of the output files, without extension")
+	width := flags.Int("width", rectpack.DefaultSize, "maximum width of each page")
+	height := flags.Int("height", rectpack.DefaultSize, "maximum height of each page")
+	pages := flags.Int("pages", 1, "maximum number of pages, or 0 for no limit")
+	heuristicName := flags.String("heuristic", "Skyline-BL", "packing `heuristic`, such as MaxRects-BSSF or Guillotine-BAF-MINAS")
+	padding := flags.Int("padding", 0, "empty space between images")
+	allowFlip := flags.Bool("allow-flip", false, "allow images to be rotated for a better fit")
+	extrude := flags.Int("extrude", 0, "repeat the border pixels of each image outward by this many pixels")
+	bleed := flags.Bool("bleed", false, "fill transparent pixels with the colour of the nearest visible pixel")
+	trim := flags.Bool("trim", false, "remove the transparent edges of each image before packing")
+	trimThreshold := flags.Uint("trim-threshold", 0, "largest alpha `value` (0-255) considered transparent when trimming")
+	dedupe := flags.Bool("dedupe", false, "pack identical images only once")
+	block := flags.Int("block", 0, "align images to blocks of this size for texture compression, such as 4 for BCn/ETC2")
+	sortName := flags.String("sort", "area", "sort `function` (area, perimeter, diff, minside, maxside, ratio, none)")
+	reverse := flags.Bool("reverse", false, "reverse the sort order")
+	online := flags.Bool("online", false, "pack images one at a time in the order they are matched instead of sorting them first")
+	validate := flags.Bool("validate", false, "verify that no images overlap or violate their padding before writing the atlas")
+	formatName := flags.String("format", "rectpack", "metadata `format` (rectpack, texturepacker-hash, texturepacker-array, phaser3, libgdx, starling)")
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if *trimThreshold > 255 {
+		fmt.Fprintf(stderr, "trim threshold must be in the range of 0 to 255 (given %d)\n", *trimThreshold)
+		return exitUsage
+	}
+	heuristic, err := rectpack.ParseHeuristic(*heuristicName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	meta, ok := format.Lookup(*formatName)
+	if !ok {
+		fmt.Fprintf(stderr, "unknown format %q\n", *formatName)
+		return exitUsage
+	}
+	sorter, err := rectpack.ParseSortFunc(*sortName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	packer, err := rectpack.NewMultiPacker(*width, *height, heuristic)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	packer.Padding = *padding
+	packer.Online = *online
+	packer.MaxBins = *pages
+	packer.AllowFlip(*allowFlip)
+	packer.BlockAlign(*block)
+	packer.Sorter(sorter, *reverse)
+
+	builder := atlas.NewMultiBuilder(packer)
+	builder.Extrude = *extrude
+	builder.Bleed = *bleed
+	builder.Trim = *trim
+	builder.Dedupe = *dedupe
+	builder.TrimThreshold = uint8(*trimThreshold)
+	builder.CounterClockwise = meta.CounterClockwise
+	for _, pattern := range flags.Args() {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		for _, path := range paths {
+			if err := builder.AddFile(path); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitError
+			}
+		}
+	}
+
+	if builder.Len() == 0 {
+		fmt.Fprintln(stderr, "no images matched the given patterns")
+		return exitError
+	}
+
+	result, buildErr := builder.Build()
+	var fitErr *atlas.FitError
+	if buildErr != nil && !errors.As(buildErr, &fitErr) {
+		fmt.Fprintln(stderr, buildErr)
+		return exitError
+	}
+
+	if *validate {
+		if code := report(stderr, packer.Validate(nil)); code != 0 {
+			return code
+		}
+	}
+
+	if err := write(*out, meta, result); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if fitErr != nil {
+		fmt.Fprintln(stderr, fitErr)
+		for _, name := range fitErr.Names {
+			fmt.Fprintf(stderr, "\t%s\n", name)
+		}
+		return exitNoFit
+	}
+	return 0
+}
+
+// report prints each violation of the layout, returning exitInvalid if there are any.
+func report(stderr io.Writer, violations []rectpack.Violation) int {
+	for _, violation := range violations {
+		fmt.Fprintln(stderr, violation.String())
+	}
+	if len(violations) > 0 {
+		return exitInvalid
+	}
+	return 0
+}
+
+// write saves each page of the atlas as a PNG image, and the metadata describing it in the
+// specified format.
+func write(out string, meta format.Format, result *atlas.Atlas) error {
+	images := make([]string, len(result.Pages))
+	for i, img := range result.Pages {
+		path := out + ".png"
+		if len(result.Pages) > 1 {
+			path = fmt.Sprintf("%s%d.png", out, i)
+		}
+
+		if err := writePNG(path, img); err != nil {
+			return err
+		}
+		images[i] = filepath.Base(path)
+	}
+
+	file, err := os.Create(out + meta.Ext)
+	if err != nil {
+		return err
+	}
+
+	if err := meta.Encode(file, result.Metadata(images)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writePNG encodes the image to a PNG file at the specified path.
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// vim: ts=4
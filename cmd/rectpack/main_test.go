@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+	"github.com/ForeverZer0/rectpack/format"
+)
+
+// writeImage creates an opaque PNG image of the specified size, returning its path.
+func writeImage(t *testing.T, dir, name string, width, height int) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	path := filepath.Join(dir, name)
+	if err := writePNG(path, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// readSprites decodes the metadata written in the native format, returning the location of each
+// sprite by name.
+func readSprites(t *testing.T, path string) map[string]rectpack.Rect {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	pages, err := format.DecodeNative(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sprites := make(map[string]rectpack.Rect)
+	for _, page := range pages {
+		for _, sprite := range page.Sprites {
+			sprites[sprite.Name] = sprite.Rect
+		}
+	}
+	return sprites
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	small := writeImage(t, dir, "small.png", 8, 8)
+	large := writeImage(t, dir, "large.png", 16, 16)
+	if err := os.WriteFile(filepath.Join(dir, "broken.png"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "atlas")
+	partial := filepath.Join(dir, "partial")
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"NoPatterns", nil, exitUsage},
+		{"UnknownFlag", []string{"-unknown", small}, exitUsage},
+		{"Heuristic", []string{"-heuristic", "Skyline-BSSF", small}, exitUsage},
+		{"Format", []string{"-format", "unknown", small}, exitUsage},
+		{"Sort", []string{"-sort", "unknown", small}, exitUsage},
+		{"TrimThreshold", []string{"-trim-threshold", "256", small}, exitUsage},
+		{"Pattern", []string{"["}, exitUsage},
+		{"NoMatches", []string{filepath.Join(dir, "*.gif")}, exitError},
+		{"Decode", []string{filepath.Join(dir, "broken.png")}, exitError},
+		{"Output", []string{"-out", filepath.Join(dir, "missing", "atlas"), small}, exitError},
+		{"NoFit", []string{"-out", partial, "-width", "12", "-height", "12", small, large}, exitNoFit},
+		{"Validate", []string{"-out", out, "-validate", "-padding", "2", "-allow-flip", small, large}, 0},
+		{"Formats", []string{"-out", out, "-format", "libgdx", small, large}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			if code := run(test.args, &stderr); code != test.code {
+				t.Errorf("expected exit code %d, got %d: %s", test.code, code, stderr.String())
+			}
+		})
+	}
+
+	// Images that fit are still written when others do not.
+	if sprites := readSprites(t, partial+".json"); len(sprites) != 1 || sprites[small].Width != 8 {
+		t.Errorf("expected only the small image to be written, got %v", sprites)
+	}
+}
+
+func TestOnline(t *testing.T) {
+	dir := t.TempDir()
+	small := writeImage(t, dir, "small.png", 8, 8)
+	large := writeImage(t, dir, "large.png", 16, 16)
+	out := filepath.Join(dir, "atlas")
+
+	// Offline packing places the image with the best fit first, while online packing keeps the
+	// order of the patterns.
+	for _, online := range []bool{false, true} {
+		args := []string{"-out", out, large, small}
+		first := small
+		if online {
+			args = append([]string{"-online"}, args...)
+			first = large
+		}
+
+		var stderr bytes.Buffer
+		if code := run(args, &stderr); code != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+		}
+		if rect := readSprites(t, out+".json")[first]; rect.X != 0 || rect.Y != 0 {
+			t.Errorf("online %t: expected %s to be packed first, got %s", online, filepath.Base(first), rect.String())
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := report(&stderr, nil); code != 0 || stderr.Len() != 0 {
+		t.Errorf("expected exit code 0 without output for a valid layout, got %d", code)
+	}
+
+	violations := []rectpack.Violation{{
+		Kind:  rectpack.ViolationOverlap,
+		Rect:  rectpack.NewRect(0, 0, 8, 8),
+		Other: rectpack.NewRect(4, 4, 8, 8),
+	}}
+	if code := report(&stderr, violations); code != exitInvalid {
+		t.Errorf("expected exit code %d, got %d", exitInvalid, code)
+	}
+	if !strings.Contains(stderr.String(), "overlap") {
+		t.Errorf("expected the violation to be printed, got %q", stderr.String())
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,120 @@
+package rectpack
+
+import "context"
+
+// canceler is implemented by algorithms that support stopping between placements, which is all
+// of the built-in algorithms.
+type canceler interface {
+	// setDone sets a channel that stops packing once it is closed, or nil to remove it.
+	setDone(done <-chan struct{})
+	// interrupted tests whether packing was stopped by the done channel since it was set.
+	interrupted() bool
+}
+
+func (p *algorithmBase) setDone(done <-chan struct{}) {
+	p.done = done
+	p.stopped = false
+}
+
+func (p *algorithmBase) interrupted() bool {
+	return p.stopped
+}
+
+// canceled tests whether the done channel has been closed, in which case no more rectangles are
+// packed. It is only called while sizes remain to be tried, so that a channel closed after the
+// last of them does not count as stopping early.
+func (p *algorithmBase) canceled() bool {
+	select {
+	case <-p.done:
+		p.stopped = true
+		return true
+	default:
+		return false
+	}
+}
+
+// insertContext packs the sizes with the algorithm, stopping between placements once the context
+// is done. Returns the sizes that were not packed, along with the error of the context if it
+// stopped packing before all of them were tried. Custom algorithms cannot be stopped once they
+// have begun, so the error is always nil after they return.
+func insertContext(ctx context.Context, algo Algorithm, padding int, sizes []Size) ([]Size, error) {
+	if err := ctx.Err(); err != nil {
+		return sizes, err
+	}
+	stopper, ok := algo.(canceler)
+	if !ok {
+		return algo.Insert(padding, sizes...), nil
+	}
+
+	stopper.setDone(ctx.Done())
+	defer stopper.setDone(nil)
+	failed := algo.Insert(padding, sizes...)
+	if stopper.interrupted() {
+		return failed, ctx.Err()
+	}
+	return failed, nil
+}
+
+// InsertContext adds rectangles to the packer, see Insert for details.
+//
+// When online mode is enabled, packing stops between placements once the context is done. The
+// rectangles that were placed remain packed, and the sizes that were not are returned along with
+// the error of the context if it stopped packing early. When online mode is disabled, the sizes
+// are only staged, and the error is always nil.
+func (p *Packer) InsertContext(ctx context.Context, sizes ...Size) ([]Size, error) {
+	if p.Online {
+		return insertContext(ctx, p.algo, p.Padding, sizes)
+	}
+	return p.Insert(sizes...), nil
+}
+
+// PackContext will sort and pack all rectangles that are currently staged, stopping between
+// placements once the context is done, such as to abandon a long pack with MaxRectsCP.
+//
+// The rectangles that were placed remain packed, and the sizes that were not remain staged, so
+// that a later call to Pack resumes where it stopped. Returns the staged sizes, along with the
+// error of the context if it stopped packing before all of them were tried. The sizes may include
+// those that were tried and did not fit.
+func (p *Packer) PackContext(ctx context.Context) ([]Size, error) {
+	if len(p.unpacked) == 0 {
+		return p.unpacked, nil
+	}
+
+	sortSizes(p.unpacked, p.sortFunc, p.sortRev)
+	failed, err := insertContext(ctx, p.algo, p.Padding, p.unpacked)
+	p.unpacked = failed
+	return p.unpacked, err
+}
+
+// InsertContext adds rectangles to the packer, see Insert for details.
+//
+// When online mode is enabled, packing stops between placements once the context is done, and no
+// more bins are opened. The rectangles that were placed remain packed, and the sizes that were
+// not are returned along with the error of the context if it stopped packing early. When online
+// mode is disabled, the sizes are only staged, and the error is always nil.
+func (p *MultiPacker) InsertContext(ctx context.Context, sizes ...Size) ([]Size, error) {
+	if p.Online {
+		return p.insert(ctx, sizes)
+	}
+	return p.Insert(sizes...), nil
+}
+
+// PackContext will sort and pack all rectangles that are currently staged, opening new bins as
+// required, and stopping between placements once the context is done.
+//
+// The rectangles that were placed remain packed, and the sizes that were not remain staged, so
+// that a later call to Pack resumes where it stopped. Returns the staged sizes, along with the
+// error of the context if it stopped packing before all of them were tried. The sizes may include
+// those that were tried and did not fit.
+func (p *MultiPacker) PackContext(ctx context.Context) ([]Size, error) {
+	if len(p.unpacked) == 0 {
+		return p.unpacked, nil
+	}
+
+	sortSizes(p.unpacked, p.sortFunc, p.sortRev)
+	failed, err := p.insert(ctx, p.unpacked)
+	p.unpacked = failed
+	return p.unpacked, err
+}
+
+// vim: ts=4
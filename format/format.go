@@ -0,0 +1,152 @@
+// Package format encodes and decodes the metadata of texture atlases in the formats used by
+// common game engines and tools, such as TexturePacker, libGDX, Starling, and Phaser.
+package format
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// app is the URL written to formats that record the application that created them.
+const app = "https://github.com/ForeverZer0/rectpack"
+
+// ErrPageCount is returned when encoding a format that only supports a single page with any
+// other number of pages.
+var ErrPageCount = errors.New("format only supports a single page")
+
+// Sprite describes a named image that has been packed into an atlas.
+type Sprite struct {
+	// Name is the unique name of the image, typically its file name.
+	Name string
+	// Rect is the area of the page that the image occupies. When flipped, the image is stored
+	// rotated 90 degrees in the direction expected by the format, and the size is that of the
+	// rotated image.
+	rectpack.Rect
+	// Source is the size of the original image before it was trimmed. An empty size indicates
+	// the image was not trimmed.
+	Source rectpack.Size
+	// Offset is the location of the top-left corner of the trimmed image within the original
+	// image.
+	Offset rectpack.Point
+}
+
+// Trimmed tests whether the sprite was trimmed from a larger image.
+func (s *Sprite) Trimmed() bool {
+	if s.Source.Width == 0 && s.Source.Height == 0 {
+		return false
+	}
+	size := s.Unrotated()
+	return s.Offset.X != 0 || s.Offset.Y != 0 || !s.Source.Eq(size)
+}
+
+// Unrotated returns the size of the image as it was before being flipped.
+func (s *Sprite) Unrotated() rectpack.Size {
+	if s.Flipped {
+		return rectpack.NewSize(s.Height, s.Width)
+	}
+	return rectpack.NewSize(s.Width, s.Height)
+}
+
+// SourceSize returns the size of the original image, which is the unrotated size of the
+// sprite when it was not trimmed.
+func (s *Sprite) SourceSize() rectpack.Size {
+	if s.Source.Width == 0 && s.Source.Height == 0 {
+		return s.Unrotated()
+	}
+	return rectpack.NewSize(s.Source.Width, s.Source.Height)
+}
+
+// Page describes a single image of an atlas and the sprites drawn onto it.
+type Page struct {
+	// Image is the file name of the page image.
+	Image string
+	// Size is the dimensions of the page image.
+	Size rectpack.Size
+	// Sprites contains each image drawn onto the page.
+	Sprites []Sprite
+}
+
+// Packer creates a packer with the size of the page and the specified heuristics, with each
+// sprite already placed at its current location. New sprites can then be packed into the
+// remaining space without moving any existing ones. The ID of each placed rectangle is the index
+// of its sprite.
+//
+// The padding is applied to the existing sprites in the same way as if they had been packed.
+//
+// Returns an error if the packer cannot be created, or if a sprite is outside of the page or
+// overlaps another sprite. In the latter case the packer is still returned, containing the
+// sprites that could be placed.
+func (p *Page) Packer(heuristic rectpack.Heuristic, padding int) (*rectpack.Packer, error) {
+	packer, err := rectpack.NewPacker(p.Size.Width, p.Size.Height, heuristic)
+	if err != nil {
+		return nil, err
+	}
+	packer.Padding = padding
+
+	rects := make([]rectpack.Rect, len(p.Sprites))
+	for i := range p.Sprites {
+		rects[i] = p.Sprites[i].Rect
+		rects[i].ID = i
+		rects[i].Bin = 0
+	}
+
+	if failed := packer.Place(rects...); len(failed) > 0 {
+		name := p.Sprites[failed[0].ID].Name
+		return packer, fmt.Errorf("cannot place %d sprite(s), including %q", len(failed), name)
+	}
+	return packer, nil
+}
+
+// fitSize sets the size of the page to the extents of its sprites if it is empty, for formats
+// that do not record it.
+func (p *Page) fitSize() {
+	if p.Size.Width != 0 || p.Size.Height != 0 {
+		return
+	}
+	for i := range p.Sprites {
+		p.Size.Width = max(p.Size.Width, p.Sprites[i].Right())
+		p.Size.Height = max(p.Size.Height, p.Sprites[i].Bottom())
+	}
+}
+
+// Format describes a metadata format and how to encode and decode it.
+type Format struct {
+	// Name is a short name that identifies the format.
+	Name string
+	// Ext is the file extension conventionally used with the format, including the leading dot.
+	Ext string
+	// Encode writes the pages of an atlas in the format.
+	Encode func(w io.Writer, pages []Page) error
+	// Decode reads the pages of an atlas in the format. The ID of each sprite is its index
+	// within its page, and the Bin is the index of the page.
+	Decode func(r io.Reader) ([]Page, error)
+	// CounterClockwise indicates that flipped sprites are stored rotated 90 degrees
+	// counter-clockwise rather than clockwise.
+	CounterClockwise bool
+}
+
+// Formats contains each of the supported formats.
+var Formats = []Format{
+	{Name: "rectpack", Ext: ".json", Encode: EncodeNative, Decode: DecodeNative},
+	{Name: "texturepacker-hash", Ext: ".json", Encode: EncodeTexturePackerHash, Decode: DecodeTexturePacker},
+	{Name: "texturepacker-array", Ext: ".json", Encode: EncodeTexturePackerArray, Decode: DecodeTexturePacker},
+	{Name: "phaser3", Ext: ".json", Encode: EncodePhaser3, Decode: DecodeTexturePacker},
+	{Name: "libgdx", Ext: ".atlas", Encode: EncodeLibGDX, Decode: DecodeLibGDX, CounterClockwise: true},
+	{Name: "starling", Ext: ".xml", Encode: EncodeStarling, Decode: DecodeStarling},
+}
+
+// Lookup returns the format with the specified name. The comparison is case-insensitive.
+func Lookup(name string) (Format, bool) {
+	for _, format := range Formats {
+		if strings.EqualFold(format.Name, name) {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// vim: ts=4
@@ -0,0 +1,192 @@
+package format
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testPages returns pages containing a plain, a flipped, and a trimmed sprite.
+func testPages() []Page {
+	flipped := rectpack.NewRect(32, 0, 16, 8)
+	flipped.Flipped = true
+
+	trimmed := Sprite{Name: "trimmed.png", Rect: rectpack.NewRect(0, 32, 20, 10)}
+	trimmed.Source = rectpack.NewSize(24, 16)
+	trimmed.Offset = rectpack.NewPoint(2, 3)
+
+	return []Page{
+		{
+			Image: "atlas.png",
+			Size:  rectpack.NewSize(64, 64),
+			Sprites: []Sprite{
+				{Name: "plain.png", Rect: rectpack.NewRect(0, 0, 32, 32)},
+				{Name: "flipped.png", Rect: flipped},
+				trimmed,
+			},
+		},
+	}
+}
+
+// checkGolden compares the output with the contents of the golden file, or updates the file when
+// the -update flag is set.
+func checkGolden(t *testing.T, name string, output []byte) {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, output, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output, expected) {
+		t.Errorf("%s: output does not match golden file\n%s", name, output)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	for _, format := range Formats {
+		var buffer bytes.Buffer
+		if err := format.Encode(&buffer, testPages()); err != nil {
+			t.Fatalf("%s: %v", format.Name, err)
+		}
+		checkGolden(t, format.Name, buffer.Bytes())
+	}
+}
+
+func TestPageCount(t *testing.T) {
+	pages := append(testPages(), testPages()...)
+	for _, encode := range []func(io.Writer, []Page) error{
+		EncodeTexturePackerHash,
+		EncodeTexturePackerArray,
+		EncodeStarling,
+	} {
+		if err := encode(&bytes.Buffer{}, pages); err != ErrPageCount {
+			t.Errorf("expected ErrPageCount, got %v", err)
+		}
+	}
+}
+
+// sortedSprites returns the sprites of a page sorted by name, with the ID of each cleared so they
+// can be compared regardless of the order a format stores them.
+func sortedSprites(page *Page) []Sprite {
+	sprites := slices.Clone(page.Sprites)
+	for i := range sprites {
+		sprites[i].ID = 0
+	}
+	slices.SortFunc(sprites, func(a, b Sprite) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return sprites
+}
+
+func TestDecode(t *testing.T) {
+	expected := testPages()
+	for _, format := range Formats {
+		var buffer bytes.Buffer
+		if err := format.Encode(&buffer, expected); err != nil {
+			t.Fatalf("%s: %v", format.Name, err)
+		}
+
+		pages, err := format.Decode(&buffer)
+		if err != nil {
+			t.Fatalf("%s: %v", format.Name, err)
+		}
+		if len(pages) != 1 || pages[0].Image != expected[0].Image {
+			t.Fatalf("%s: pages do not match", format.Name)
+		}
+		// Starling does not record the size of the page, so it is the extents of the sprites.
+		if format.Name != "starling" && pages[0].Size != expected[0].Size {
+			t.Errorf("%s: expected page size %v, got %v", format.Name, expected[0].Size, pages[0].Size)
+		}
+		for i, sprite := range pages[0].Sprites {
+			if sprite.ID != i {
+				t.Errorf("%s: expected ID %d for %s, got %d", format.Name, i, sprite.Name, sprite.ID)
+			}
+		}
+		if !slices.Equal(sortedSprites(&pages[0]), sortedSprites(&expected[0])) {
+			t.Errorf("%s: sprites do not match\n%v", format.Name, pages[0].Sprites)
+		}
+	}
+}
+
+func TestPagePacker(t *testing.T) {
+	page := testPages()[0]
+	packer, err := page.Packer(rectpack.MaxRectsBSSF, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rects := packer.Rects()
+	if len(rects) != len(page.Sprites) {
+		t.Fatalf("expected %d rectangles, got %d", len(page.Sprites), len(rects))
+	}
+	for _, rect := range rects {
+		expected := page.Sprites[rect.ID].Rect
+		expected.ID = rect.ID
+		if rect != expected {
+			t.Errorf("expected %s, got %s", expected.String(), rect.String())
+		}
+	}
+
+	packer.Online = true
+	for i := 0; packer.InsertSize(len(page.Sprites)+i, 8, 8); i++ {
+	}
+	rects = packer.Rects()
+	for i := 0; i < len(rects)-1; i++ {
+		for j := i + 1; j < len(rects); j++ {
+			if rects[i].Intersects(rects[j]) {
+				t.Errorf("%s and %s intersect", rects[i].String(), rects[j].String())
+			}
+		}
+	}
+
+	page.Sprites = append(page.Sprites, Sprite{Name: "overlap.png", Rect: rectpack.NewRect(4, 4, 8, 8)})
+	if _, err := page.Packer(rectpack.MaxRectsBSSF, 0); err == nil {
+		t.Error("expected error for overlapping sprite")
+	}
+}
+
+func TestDecodeLibGDX(t *testing.T) {
+	// The newer format written by libGDX 1.9.12 and later.
+	const input = `atlas.png
+size: 64, 64
+filter: Linear, Linear
+pma: true
+plain.png
+  bounds: 0, 0, 32, 32
+trimmed.png
+  bounds: 0, 32, 20, 10
+  offsets: 2, 3, 24, 16
+flipped.png
+  bounds: 32, 0, 8, 16
+  rotate: 90
+`
+	pages, err := DecodeLibGDX(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Size != rectpack.NewSize(64, 64) {
+		t.Fatalf("pages do not match: %v", pages)
+	}
+
+	expected := testPages()
+	if !slices.Equal(sortedSprites(&pages[0]), sortedSprites(&expected[0])) {
+		t.Errorf("sprites do not match\n%v", pages[0].Sprites)
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,210 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// EncodeLibGDX writes the pages in the text format used by libGDX and Spine, which supports any
+// number of pages.
+//
+// The size of each region is its unrotated size, and the offset is measured from the
+// bottom-left corner of the original image, as expected by libGDX. Flipped sprites must be
+// stored rotated 90 degrees counter-clockwise, such as by setting CounterClockwise when building
+// the atlas.
+func EncodeLibGDX(w io.Writer, pages []Page) error {
+	buffer := bufio.NewWriter(w)
+	for i := range pages {
+		page := &pages[i]
+		fmt.Fprintf(buffer, "\n%s\n", page.Image)
+		fmt.Fprintf(buffer, "size: %d, %d\n", page.Size.Width, page.Size.Height)
+		fmt.Fprintln(buffer, "format: RGBA8888")
+		fmt.Fprintln(buffer, "filter: Nearest, Nearest")
+		fmt.Fprintln(buffer, "repeat: none")
+
+		for j := range page.Sprites {
+			sprite := &page.Sprites[j]
+			size := sprite.Unrotated()
+			source := sprite.SourceSize()
+			offsetY := source.Height - sprite.Offset.Y - size.Height
+
+			fmt.Fprintln(buffer, sprite.Name)
+			fmt.Fprintf(buffer, "  rotate: %t\n", sprite.Flipped)
+			fmt.Fprintf(buffer, "  xy: %d, %d\n", sprite.X, sprite.Y)
+			fmt.Fprintf(buffer, "  size: %d, %d\n", size.Width, size.Height)
+			fmt.Fprintf(buffer, "  orig: %d, %d\n", source.Width, source.Height)
+			fmt.Fprintf(buffer, "  offset: %d, %d\n", sprite.Offset.X, offsetY)
+			fmt.Fprintln(buffer, "  index: -1")
+		}
+	}
+	return buffer.Flush()
+}
+
+// gdxRegion contains the properties of a region in the libGDX text format as they are read.
+type gdxRegion struct {
+	name   string
+	rotate bool
+	xy     []int
+	size   []int
+	orig   []int
+	offset []int
+}
+
+// sprite converts the region to a sprite, with an ID that is its index within the page.
+func (r *gdxRegion) sprite(page *Page) Sprite {
+	size := rectpack.NewSize(r.size[0], r.size[1])
+	sprite := Sprite{Name: r.name, Rect: rectpack.NewRect(r.xy[0], r.xy[1], size.Width, size.Height)}
+	sprite.ID = len(page.Sprites)
+	if r.rotate {
+		sprite.Width, sprite.Height = size.Height, size.Width
+		sprite.Flipped = true
+	}
+
+	if r.orig != nil {
+		source := rectpack.NewSize(r.orig[0], r.orig[1])
+		offset := rectpack.NewPoint(0, 0)
+		if r.offset != nil {
+			offset = rectpack.NewPoint(r.offset[0], source.Height-r.offset[1]-size.Height)
+		}
+		if !source.Eq(size) || offset.X != 0 || offset.Y != 0 {
+			sprite.Source = source
+			sprite.Offset = offset
+		}
+	}
+	return sprite
+}
+
+// parseInts parses a comma-separated list of exactly count integers.
+func parseInts(value string, count int) ([]int, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != count {
+		return nil, fmt.Errorf("expected %d values, got %d", count, len(fields))
+	}
+
+	values := make([]int, count)
+	for i, field := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = n
+	}
+	return values, nil
+}
+
+// set assigns a property of the region from its key and value.
+func (r *gdxRegion) set(key, value string) (err error) {
+	switch key {
+	case "rotate":
+		// Newer versions write the angle in degrees rather than a boolean.
+		r.rotate = value == "true" || value == "90"
+	case "xy":
+		r.xy, err = parseInts(value, 2)
+	case "size":
+		r.size, err = parseInts(value, 2)
+	case "bounds":
+		var bounds []int
+		if bounds, err = parseInts(value, 4); err == nil {
+			r.xy, r.size = bounds[:2], bounds[2:]
+		}
+	case "orig":
+		r.orig, err = parseInts(value, 2)
+	case "offset":
+		r.offset, err = parseInts(value, 2)
+	case "offsets":
+		var offsets []int
+		if offsets, err = parseInts(value, 4); err == nil {
+			r.offset, r.orig = offsets[:2], offsets[2:]
+		}
+	}
+	return err
+}
+
+// DecodeLibGDX reads pages in the text format used by libGDX and Spine. Both the legacy format
+// and the newer format with "bounds" and "offsets" properties are supported. Flipped sprites are
+// stored rotated 90 degrees counter-clockwise.
+//
+// When the size of a page is not recorded, it is computed from the extents of the sprites.
+func DecodeLibGDX(r io.Reader) ([]Page, error) {
+	var pages []Page
+	var page *Page
+	var region *gdxRegion
+
+	finish := func() error {
+		if region == nil {
+			return nil
+		}
+		if region.xy == nil || region.size == nil {
+			return fmt.Errorf("region %q: missing location or size", region.name)
+		}
+		page.Sprites = append(page.Sprites, region.sprite(page))
+		region = nil
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(text)
+		key, value, isProperty := strings.Cut(trimmed, ":")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		var err error
+		switch {
+		case trimmed == "":
+			// A blank line ends the current page.
+			if page != nil {
+				err = finish()
+				page.fitSize()
+				page = nil
+			}
+		case page == nil:
+			pages = append(pages, Page{Image: trimmed})
+			page = &pages[len(pages)-1]
+		case text != trimmed && isProperty:
+			if region == nil {
+				return nil, fmt.Errorf("line %d: property outside of a region", line)
+			}
+			err = region.set(key, value)
+		case isProperty && region == nil && len(page.Sprites) == 0:
+			if key == "size" {
+				var size []int
+				if size, err = parseInts(value, 2); err == nil {
+					page.Size = rectpack.NewSize(size[0], size[1])
+				}
+			}
+		default:
+			if err = finish(); err == nil {
+				region = &gdxRegion{name: trimmed}
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if page != nil {
+		if err := finish(); err != nil {
+			return nil, err
+		}
+		page.fitSize()
+	}
+
+	for i := range pages {
+		for j := range pages[i].Sprites {
+			pages[i].Sprites[j].Bin = i
+		}
+	}
+	return pages, nil
+}
+
+// vim: ts=4
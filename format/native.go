@@ -0,0 +1,91 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// nativeAtlas is the JSON representation of the native format.
+type nativeAtlas struct {
+	Pages   []nativePage            `json:"pages"`
+	Sprites map[string]nativeSprite `json:"sprites"`
+}
+
+// nativePage is the JSON representation of a page in the native format.
+type nativePage struct {
+	Image string `json:"image"`
+	rectpack.Size
+}
+
+// nativeSprite is the JSON representation of a sprite in the native format.
+type nativeSprite struct {
+	rectpack.Rect
+	Source *rectpack.Size  `json:"source,omitempty"`
+	Offset *rectpack.Point `json:"offset,omitempty"`
+}
+
+// EncodeNative writes the pages in the native JSON format of this library, which maps the name
+// of each sprite to its rectangle. The Bin field of each rectangle is the index of its page.
+func EncodeNative(w io.Writer, pages []Page) error {
+	atlas := nativeAtlas{Sprites: make(map[string]nativeSprite)}
+	for i, page := range pages {
+		atlas.Pages = append(atlas.Pages, nativePage{Image: page.Image, Size: page.Size})
+		for _, sprite := range page.Sprites {
+			value := nativeSprite{Rect: sprite.Rect}
+			value.Bin = i
+			if sprite.Trimmed() {
+				source, offset := sprite.Source, sprite.Offset
+				value.Source = &source
+				value.Offset = &offset
+			}
+			atlas.Sprites[sprite.Name] = value
+		}
+	}
+
+	return encodeJSON(w, atlas)
+}
+
+// DecodeNative reads pages in the native JSON format of this library. Sprites are sorted by name
+// within each page.
+func DecodeNative(r io.Reader) ([]Page, error) {
+	var atlas nativeAtlas
+	if err := json.NewDecoder(r).Decode(&atlas); err != nil {
+		return nil, err
+	}
+
+	pages := make([]Page, len(atlas.Pages))
+	for i, page := range atlas.Pages {
+		pages[i] = Page{Image: page.Image, Size: page.Size}
+	}
+
+	names := make([]string, 0, len(atlas.Sprites))
+	for name := range atlas.Sprites {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		value := atlas.Sprites[name]
+		if value.Bin < 0 || value.Bin >= len(pages) {
+			return nil, fmt.Errorf("sprite %q: invalid page index %d", name, value.Bin)
+		}
+
+		page := &pages[value.Bin]
+		sprite := Sprite{Name: name, Rect: value.Rect}
+		sprite.ID = len(page.Sprites)
+		if value.Source != nil {
+			sprite.Source = *value.Source
+		}
+		if value.Offset != nil {
+			sprite.Offset = *value.Offset
+		}
+		page.Sprites = append(page.Sprites, sprite)
+	}
+	return pages, nil
+}
+
+// vim: ts=4
@@ -0,0 +1,97 @@
+package format
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// starlingAtlas is the root element of the Starling/Sparrow XML format.
+type starlingAtlas struct {
+	XMLName     xml.Name             `xml:"TextureAtlas"`
+	ImagePath   string               `xml:"imagePath,attr"`
+	SubTextures []starlingSubTexture `xml:"SubTexture"`
+}
+
+// starlingSubTexture describes a single sprite in the Starling/Sparrow XML format. Unlike most
+// formats, the size is that of the area occupied in the atlas, which is rotated when flipped.
+type starlingSubTexture struct {
+	Name        string `xml:"name,attr"`
+	X           int    `xml:"x,attr"`
+	Y           int    `xml:"y,attr"`
+	Width       int    `xml:"width,attr"`
+	Height      int    `xml:"height,attr"`
+	FrameX      int    `xml:"frameX,attr,omitempty"`
+	FrameY      int    `xml:"frameY,attr,omitempty"`
+	FrameWidth  int    `xml:"frameWidth,attr,omitempty"`
+	FrameHeight int    `xml:"frameHeight,attr,omitempty"`
+	Rotated     bool   `xml:"rotated,attr,omitempty"`
+}
+
+// EncodeStarling writes a single page in the Starling/Sparrow XML format.
+//
+// Returns ErrPageCount when there is not exactly one page.
+func EncodeStarling(w io.Writer, pages []Page) error {
+	if len(pages) != 1 {
+		return ErrPageCount
+	}
+
+	page := &pages[0]
+	atlas := starlingAtlas{ImagePath: page.Image, SubTextures: make([]starlingSubTexture, len(page.Sprites))}
+	for i := range page.Sprites {
+		sprite := &page.Sprites[i]
+		texture := starlingSubTexture{
+			Name:    sprite.Name,
+			X:       sprite.X,
+			Y:       sprite.Y,
+			Width:   sprite.Width,
+			Height:  sprite.Height,
+			Rotated: sprite.Flipped,
+		}
+		if sprite.Trimmed() {
+			texture.FrameX = -sprite.Offset.X
+			texture.FrameY = -sprite.Offset.Y
+			texture.FrameWidth = sprite.Source.Width
+			texture.FrameHeight = sprite.Source.Height
+		}
+		atlas.SubTextures[i] = texture
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "\t")
+	if err := encoder.Encode(atlas); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// DecodeStarling reads a single page in the Starling/Sparrow XML format. The format does not
+// record the size of the page, so it is computed from the extents of the sprites.
+func DecodeStarling(r io.Reader) ([]Page, error) {
+	var atlas starlingAtlas
+	if err := xml.NewDecoder(r).Decode(&atlas); err != nil {
+		return nil, err
+	}
+
+	page := Page{Image: atlas.ImagePath, Sprites: make([]Sprite, len(atlas.SubTextures))}
+	for i, texture := range atlas.SubTextures {
+		sprite := Sprite{Name: texture.Name, Rect: rectpack.NewRect(texture.X, texture.Y, texture.Width, texture.Height)}
+		sprite.ID = i
+		sprite.Flipped = texture.Rotated
+		if texture.FrameWidth != 0 || texture.FrameHeight != 0 {
+			sprite.Source = rectpack.NewSize(texture.FrameWidth, texture.FrameHeight)
+			sprite.Offset = rectpack.NewPoint(-texture.FrameX, -texture.FrameY)
+		}
+		page.Sprites[i] = sprite
+	}
+
+	page.fitSize()
+	return []Page{page}, nil
+}
+
+// vim: ts=4
@@ -0,0 +1,247 @@
+package format
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// tpRect is a rectangle in the TexturePacker JSON formats.
+type tpRect struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// tpSize is a size in the TexturePacker JSON formats.
+type tpSize struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// tpFrame describes a single sprite in the TexturePacker JSON formats. The size of the frame is
+// always the unrotated size of the sprite.
+type tpFrame struct {
+	Filename         string `json:"filename,omitempty"`
+	Frame            tpRect `json:"frame"`
+	Rotated          bool   `json:"rotated"`
+	Trimmed          bool   `json:"trimmed"`
+	SpriteSourceSize tpRect `json:"spriteSourceSize"`
+	SourceSize       tpSize `json:"sourceSize"`
+}
+
+// tpMeta describes the atlas in the TexturePacker JSON formats.
+type tpMeta struct {
+	App     string  `json:"app"`
+	Version string  `json:"version"`
+	Image   string  `json:"image,omitempty"`
+	Format  string  `json:"format,omitempty"`
+	Size    *tpSize `json:"size,omitempty"`
+	Scale   string  `json:"scale,omitempty"`
+}
+
+// tpHash is the TexturePacker JSON hash format.
+type tpHash struct {
+	Frames map[string]tpFrame `json:"frames"`
+	Meta   tpMeta             `json:"meta"`
+}
+
+// tpArray is the TexturePacker JSON array format.
+type tpArray struct {
+	Frames []tpFrame `json:"frames"`
+	Meta   tpMeta    `json:"meta"`
+}
+
+// phaserTexture describes a single page in the Phaser 3 multi-atlas format.
+type phaserTexture struct {
+	Image  string    `json:"image"`
+	Format string    `json:"format"`
+	Size   tpSize    `json:"size"`
+	Scale  int       `json:"scale"`
+	Frames []tpFrame `json:"frames"`
+}
+
+// phaserAtlas is the Phaser 3 multi-atlas format.
+type phaserAtlas struct {
+	Textures []phaserTexture `json:"textures"`
+	Meta     tpMeta          `json:"meta"`
+}
+
+// newFrame converts a sprite to its representation in the TexturePacker JSON formats.
+func newFrame(sprite *Sprite, filename string) tpFrame {
+	size := sprite.Unrotated()
+	source := sprite.SourceSize()
+	return tpFrame{
+		Filename:         filename,
+		Frame:            tpRect{X: sprite.X, Y: sprite.Y, W: size.Width, H: size.Height},
+		Rotated:          sprite.Flipped,
+		Trimmed:          sprite.Trimmed(),
+		SpriteSourceSize: tpRect{X: sprite.Offset.X, Y: sprite.Offset.Y, W: size.Width, H: size.Height},
+		SourceSize:       tpSize{W: source.Width, H: source.Height},
+	}
+}
+
+// sprite converts a frame of the TexturePacker JSON formats to a sprite.
+func (f *tpFrame) sprite(name string, id int) Sprite {
+	sprite := Sprite{Name: name, Rect: rectpack.NewRect(f.Frame.X, f.Frame.Y, f.Frame.W, f.Frame.H)}
+	sprite.ID = id
+	if f.Rotated {
+		sprite.Width, sprite.Height = f.Frame.H, f.Frame.W
+		sprite.Flipped = true
+	}
+	if f.Trimmed {
+		sprite.Source = rectpack.NewSize(f.SourceSize.W, f.SourceSize.H)
+		sprite.Offset = rectpack.NewPoint(f.SpriteSourceSize.X, f.SpriteSourceSize.Y)
+	}
+	return sprite
+}
+
+// newMeta creates the atlas description of a page in the TexturePacker JSON formats.
+func newMeta(page *Page) tpMeta {
+	return tpMeta{
+		App:     app,
+		Version: "1.0",
+		Image:   page.Image,
+		Format:  "RGBA8888",
+		Size:    &tpSize{W: page.Size.Width, H: page.Size.Height},
+		Scale:   "1",
+	}
+}
+
+// encodeJSON writes the value as indented JSON.
+func encodeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(value)
+}
+
+// EncodeTexturePackerHash writes a single page in the TexturePacker JSON hash format, where
+// frames are keyed by the sprite name.
+//
+// Returns ErrPageCount when there is not exactly one page.
+func EncodeTexturePackerHash(w io.Writer, pages []Page) error {
+	if len(pages) != 1 {
+		return ErrPageCount
+	}
+
+	page := &pages[0]
+	atlas := tpHash{Frames: make(map[string]tpFrame, len(page.Sprites)), Meta: newMeta(page)}
+	for i := range page.Sprites {
+		atlas.Frames[page.Sprites[i].Name] = newFrame(&page.Sprites[i], "")
+	}
+	return encodeJSON(w, atlas)
+}
+
+// EncodeTexturePackerArray writes a single page in the TexturePacker JSON array format, where
+// frames are listed in order with their sprite name as the filename.
+//
+// Returns ErrPageCount when there is not exactly one page.
+func EncodeTexturePackerArray(w io.Writer, pages []Page) error {
+	if len(pages) != 1 {
+		return ErrPageCount
+	}
+
+	page := &pages[0]
+	atlas := tpArray{Frames: make([]tpFrame, len(page.Sprites)), Meta: newMeta(page)}
+	for i := range page.Sprites {
+		atlas.Frames[i] = newFrame(&page.Sprites[i], page.Sprites[i].Name)
+	}
+	return encodeJSON(w, atlas)
+}
+
+// EncodePhaser3 writes the pages in the Phaser 3 multi-atlas JSON format, which supports any
+// number of pages.
+func EncodePhaser3(w io.Writer, pages []Page) error {
+	atlas := phaserAtlas{
+		Textures: make([]phaserTexture, len(pages)),
+		Meta:     tpMeta{App: app, Version: "3"},
+	}
+
+	for i := range pages {
+		page := &pages[i]
+		texture := phaserTexture{
+			Image:  page.Image,
+			Format: "RGBA8888",
+			Size:   tpSize{W: page.Size.Width, H: page.Size.Height},
+			Scale:  1,
+			Frames: make([]tpFrame, len(page.Sprites)),
+		}
+		for j := range page.Sprites {
+			texture.Frames[j] = newFrame(&page.Sprites[j], page.Sprites[j].Name)
+		}
+		atlas.Textures[i] = texture
+	}
+	return encodeJSON(w, atlas)
+}
+
+// tpAny contains the fields of each of the TexturePacker JSON formats, where the type of the
+// frames is determined after decoding.
+type tpAny struct {
+	Frames   json.RawMessage `json:"frames"`
+	Textures []phaserTexture `json:"textures"`
+	Meta     tpMeta          `json:"meta"`
+}
+
+// DecodeTexturePacker reads pages in any of the TexturePacker JSON hash, JSON array, or Phaser 3
+// multi-atlas formats, which are distinguished by their structure. Sprites of the hash format are
+// sorted by name, while the others retain their order.
+//
+// When the size of a page is not recorded, it is computed from the extents of the sprites.
+func DecodeTexturePacker(r io.Reader) ([]Page, error) {
+	var atlas tpAny
+	if err := json.NewDecoder(r).Decode(&atlas); err != nil {
+		return nil, err
+	}
+
+	if atlas.Textures != nil {
+		pages := make([]Page, len(atlas.Textures))
+		for i, texture := range atlas.Textures {
+			page := Page{Image: texture.Image, Size: rectpack.NewSize(texture.Size.W, texture.Size.H)}
+			for j := range texture.Frames {
+				sprite := texture.Frames[j].sprite(texture.Frames[j].Filename, j)
+				sprite.Bin = i
+				page.Sprites = append(page.Sprites, sprite)
+			}
+			page.fitSize()
+			pages[i] = page
+		}
+		return pages, nil
+	}
+
+	page := Page{Image: atlas.Meta.Image}
+	if atlas.Meta.Size != nil {
+		page.Size = rectpack.NewSize(atlas.Meta.Size.W, atlas.Meta.Size.H)
+	}
+
+	var frames []tpFrame
+	if err := json.Unmarshal(atlas.Frames, &frames); err != nil {
+		var hash map[string]tpFrame
+		if json.Unmarshal(atlas.Frames, &hash) != nil {
+			return nil, errors.New("frames must be an array or an object")
+		}
+
+		names := make([]string, 0, len(hash))
+		for name := range hash {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			frame := hash[name]
+			frame.Filename = name
+			frames = append(frames, frame)
+		}
+	}
+
+	for i := range frames {
+		page.Sprites = append(page.Sprites, frames[i].sprite(frames[i].Filename, i))
+	}
+	page.fitSize()
+	return []Page{page}, nil
+}
+
+// vim: ts=4
@@ -45,6 +45,12 @@ func (p *guillotinePack) Reset(width, height int) {
 	p.algorithmBase.Reset(width, height)
 	p.freeRects = p.freeRects[:0]
 	p.freeRects = append(p.freeRects, NewRect(0, 0, p.maxWidth, p.maxHeight))
+	for _, cell := range p.reservedCells() {
+		p.subtractFreeRects(cell)
+	}
+	if p.Merge && len(p.reserved) > 0 {
+		p.mergeFreeList()
+	}
 }
 
 func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
@@ -55,25 +61,41 @@ func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
 
 	// Pack rectangles one at a time until we have cleared the rects array of all rectangles.
 	// rects will get destroyed in the process.
-	for len(sizes) > 0 {
+	for len(sizes) > 0 && !p.canceled() {
 		// Stores the penalty score of the best rectangle placement - bigger=worse, smaller=better.
 		bestScore := math.MaxInt
 
-		for i, freeRect := range p.freeRects {
+		var freeRect Rect
+		for i := range p.freeRects {
 			for j, size := range sizes {
 
-				padSize(&size, padding)
+				p.padSize(&size, padding)
+				opts := p.options(size.Options)
+				freeRect = p.freeRects[i]
+				if opts.aligned() {
+					freeRect = alignFree(freeRect, opts)
+				}
+				flip := p.canFlip(opts)
+				if p.observer != nil {
+					p.scoring = size.ID
+				}
 
 				// If this rectangle is a perfect match, we pick it instantly.
 				if size.Width == freeRect.Width && size.Height == freeRect.Height {
+					if p.observer != nil {
+						p.observeScore(freeRect, freeRect.X, freeRect.Y, size.Width, size.Height, false, math.MinInt, 0)
+					}
 					bestFreeRect = i
 					bestRect = j
 					bestFlipped = false
 					bestScore = math.MinInt
 					i = len(p.freeRects) // Force a jump out of the outer loop as well - we got an instant fit.
 					break
-				} else if p.allowFlip && size.Height == freeRect.Width && size.Width == freeRect.Height {
+				} else if flip && size.Height == freeRect.Width && size.Width == freeRect.Height {
 					// If flipping this rectangle is a perfect match, pick that then.
+					if p.observer != nil {
+						p.observeScore(freeRect, freeRect.X, freeRect.Y, size.Height, size.Width, true, math.MinInt, 0)
+					}
 					bestFreeRect = i
 					bestRect = j
 					bestFlipped = true
@@ -83,15 +105,21 @@ func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
 				} else if size.Width <= freeRect.Width && size.Height <= freeRect.Height {
 					// Try if we can fit the rectangle upright.
 					score := p.scoreRect(size.Width, size.Height, &freeRect)
+					if p.observer != nil {
+						p.observeScore(freeRect, freeRect.X, freeRect.Y, size.Width, size.Height, false, score, 0)
+					}
 					if score < bestScore {
 						bestFreeRect = i
 						bestRect = j
 						bestFlipped = false
 						bestScore = score
 					}
-				} else if p.allowFlip && size.Height <= freeRect.Width && size.Width <= freeRect.Height {
+				} else if flip && size.Height <= freeRect.Width && size.Width <= freeRect.Height {
 					// If not, then perhaps flipping sideways will make it fit?
 					score := p.scoreRect(size.Height, size.Width, &freeRect)
+					if p.observer != nil {
+						p.observeScore(freeRect, freeRect.X, freeRect.Y, size.Height, size.Width, true, score, 0)
+					}
 					if score < bestScore {
 						bestFreeRect = i
 						bestRect = j
@@ -108,10 +136,12 @@ func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
 		}
 
 		// Otherwise, we're good to go and do the actual packing.
+		size := sizes[bestRect]
 		newNode := Rect{
-			Point: p.freeRects[bestFreeRect].Point,
-			Size:  sizes[bestRect],
+			Point: alignFree(p.freeRects[bestFreeRect], p.options(size.Options)).Point,
+			Size:  size,
 		}
+		p.padSize(&newNode.Size, padding)
 
 		if bestFlipped {
 			newNode.Width, newNode.Height = newNode.Height, newNode.Width
@@ -119,8 +149,7 @@ func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
 		}
 
 		// Remove the free space we lost in the bin.
-		p.splitByHeuristic(&p.freeRects[bestFreeRect], &newNode)
-		p.freeRects = slices.Delete(p.freeRects, bestFreeRect, bestFreeRect+1)
+		p.placeNode(bestFreeRect, newNode)
 
 		// Remove the rectangle we just packed from the input list.
 		sizes = slices.Delete(sizes, bestRect, bestRect+1)
@@ -133,13 +162,69 @@ func (p *guillotinePack) Insert(padding int, sizes ...Size) []Size {
 		// Remember the new used rectangle.
 		p.usedArea += newNode.Area()
 
-		unpadRect(&newNode, padding)
-		p.packed = append(p.packed, newNode)
+		unpadRect(&newNode, size)
+		p.addPacked(newNode)
 	}
 
 	return sizes
 }
 
+func (p *guillotinePack) Remove(padding, id int) bool {
+	rect, ok := p.remove(id)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(rect, padding)
+	p.usedArea -= cell.Area()
+
+	// The area of a packed rectangle is disjoint from all free rectangles, so it can simply be
+	// returned to the pool as-is.
+	p.freeRects = append(p.freeRects, NewRect(cell.X, cell.Y, cell.Width, cell.Height))
+	if p.Merge {
+		p.mergeFreeList()
+	}
+	return true
+}
+
+func (p *guillotinePack) Place(padding int, rects ...Rect) []Rect {
+	failed := p.place(padding, rects, p.subtractFreeRects)
+	if p.Merge {
+		p.mergeFreeList()
+	}
+	return failed
+}
+
+func (p *guillotinePack) Reserve(padding int, rects ...Rect) []Rect {
+	failed := p.reserve(padding, rects, p.subtractFreeRects)
+	if p.Merge {
+		p.mergeFreeList()
+	}
+	return failed
+}
+
+func (p *guillotinePack) Unreserve(_ int, rect Rect) bool {
+	reserved, ok := p.unreserve(rect)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(reserved, 0)
+	p.usedArea -= cell.Area()
+
+	// As with Remove, the area of a reservation is disjoint from all free rectangles.
+	p.freeRects = append(p.freeRects, cell)
+	if p.Merge {
+		p.mergeFreeList()
+	}
+	return true
+}
+
+// subtractFreeRects removes an occupied area from the free rectangles.
+func (p *guillotinePack) subtractFreeRects(cell Rect) {
+	p.freeRects = subtractRect(p.freeRects, cell)
+}
+
 func scoreBestArea(width, height int, freeRect *Rect) int {
 	return freeRect.Width*freeRect.Height - width*height
 }
@@ -177,58 +262,80 @@ func (p *guillotinePack) splitAlongAxis(freeRect, placedRect *Rect, splitHorizon
 	}
 
 	// Add the new rectangles into the free rectangle pool if they weren't degenerate.
+	first := len(p.freeRects)
 	if bottom.Width > 0 && bottom.Height > 0 {
 		p.freeRects = append(p.freeRects, bottom)
 	}
 	if right.Width > 0 && right.Height > 0 {
 		p.freeRects = append(p.freeRects, right)
 	}
+	if p.observer != nil {
+		p.observeSplit(*freeRect, *placedRect, slices.Clone(p.freeRects[first:]))
+	}
 }
 
-func (p *guillotinePack) findPosition(width, height int, nodeIndex *int) Rect {
+func (p *guillotinePack) findPosition(width, height int, opts SizeOptions, nodeIndex *int) Rect {
 	var bestNode Rect
 
 	bestScore := math.MaxInt
+	flip := p.canFlip(opts)
 
 	/// Try each free rectangle to find the best one for placement.
 	for i, freeRect := range p.freeRects {
+		freeRect = alignFree(freeRect, opts)
 		// If this is a perfect fit upright, choose it immediately.
 		if width == freeRect.Width && height == freeRect.Height {
+			if p.observer != nil {
+				p.observeScore(freeRect, freeRect.X, freeRect.Y, width, height, false, math.MinInt, 0)
+			}
 			bestNode.X = freeRect.X
 			bestNode.Y = freeRect.Y
 			bestNode.Width = width
 			bestNode.Height = height
+			bestNode.Flipped = false
 			bestScore = math.MinInt
 			*nodeIndex = i
 			break
-		} else if p.allowFlip && height == freeRect.Width && width == freeRect.Height {
+		} else if flip && height == freeRect.Width && width == freeRect.Height {
 			// If this is a perfect fit sideways, choose it.
+			if p.observer != nil {
+				p.observeScore(freeRect, freeRect.X, freeRect.Y, height, width, true, math.MinInt, 0)
+			}
 			bestNode.X = freeRect.X
 			bestNode.Y = freeRect.Y
 			bestNode.Width = height
 			bestNode.Height = width
+			bestNode.Flipped = true
 			bestScore = math.MinInt
 			*nodeIndex = i
 			break
 		} else if width <= freeRect.Width && height <= freeRect.Height {
 			// Does the rectangle fit upright?
 			score := p.scoreRect(width, height, &freeRect)
+			if p.observer != nil {
+				p.observeScore(freeRect, freeRect.X, freeRect.Y, width, height, false, score, 0)
+			}
 			if score < bestScore {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestScore = score
 				*nodeIndex = i
 			}
-		} else if p.allowFlip && height <= freeRect.Width && width <= freeRect.Height {
+		} else if flip && height <= freeRect.Width && width <= freeRect.Height {
 			// Does the rectangle fit sideways?
 			score := p.scoreRect(height, width, &freeRect)
+			if p.observer != nil {
+				p.observeScore(freeRect, freeRect.X, freeRect.Y, height, width, true, score, 0)
+			}
 			if score < bestScore {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = height
 				bestNode.Height = width
+				bestNode.Flipped = true
 				bestScore = score
 				*nodeIndex = i
 			}
@@ -237,6 +344,24 @@ func (p *guillotinePack) findPosition(width, height int, nodeIndex *int) Rect {
 	return bestNode
 }
 
+// placeNode removes the area of a placed rectangle from the free rectangle at the specified
+// index. When the rectangle is at the top-left corner of the free rectangle, the remainder is
+// split using the heuristic, otherwise it was moved for alignment and the free areas around it
+// are retained.
+func (p *guillotinePack) placeNode(index int, node Rect) {
+	freeRect := p.freeRects[index]
+	p.freeRects = slices.Delete(p.freeRects, index, index+1)
+	if node.Point == freeRect.Point {
+		p.splitByHeuristic(&freeRect, &node)
+	} else {
+		parts := subtractRect([]Rect{freeRect}, node)
+		if p.observer != nil {
+			p.observeSplit(freeRect, node, parts)
+		}
+		p.freeRects = append(p.freeRects, parts...)
+	}
+}
+
 func (p *guillotinePack) splitByHeuristic(freeRect, placedRect *Rect) {
 	// Compute the lengths of the leftover area.
 	w := freeRect.Width - placedRect.Width
@@ -284,25 +409,38 @@ func (p *guillotinePack) mergeFreeList() {
 
 	for i := 0; i < len(p.freeRects); i++ {
 		for j := i + 1; j < len(p.freeRects); j++ {
-			if p.freeRects[i].Width == p.freeRects[i].Width && p.freeRects[i].X == p.freeRects[i].X {
-				if p.freeRects[i].Y == p.freeRects[i].Y+p.freeRects[i].Height {
-					p.freeRects[i].Y -= p.freeRects[i].Height
-					p.freeRects[i].Height += p.freeRects[i].Height
+			a, b := &p.freeRects[i], p.freeRects[j]
+			if a.Width == b.Width && a.X == b.X {
+				if a.Y == b.Y+b.Height {
+					if p.observer != nil {
+						p.observeMerge(*a, b)
+					}
+					a.Y -= b.Height
+					a.Height += b.Height
 					p.freeRects = slices.Delete(p.freeRects, j, j+1)
 					j--
-				} else if p.freeRects[i].Y+p.freeRects[i].Height == p.freeRects[i].Y {
-					p.freeRects[i].Height += p.freeRects[i].Height
+				} else if a.Y+a.Height == b.Y {
+					if p.observer != nil {
+						p.observeMerge(*a, b)
+					}
+					a.Height += b.Height
 					p.freeRects = slices.Delete(p.freeRects, j, j+1)
 					j--
 				}
-			} else if p.freeRects[i].Height == p.freeRects[i].Height && p.freeRects[i].Y == p.freeRects[i].Y {
-				if p.freeRects[i].X == p.freeRects[i].X+p.freeRects[i].Width {
-					p.freeRects[i].X -= p.freeRects[i].Width
-					p.freeRects[i].Width += p.freeRects[i].Width
+			} else if a.Height == b.Height && a.Y == b.Y {
+				if a.X == b.X+b.Width {
+					if p.observer != nil {
+						p.observeMerge(*a, b)
+					}
+					a.X -= b.Width
+					a.Width += b.Width
 					p.freeRects = slices.Delete(p.freeRects, j, j+1)
 					j--
-				} else if p.freeRects[i].X+p.freeRects[i].Width == p.freeRects[i].X {
-					p.freeRects[i].Width += p.freeRects[i].Width
+				} else if a.X+a.Width == b.X {
+					if p.observer != nil {
+						p.observeMerge(*a, b)
+					}
+					a.Width += b.Width
 					p.freeRects = slices.Delete(p.freeRects, j, j+1)
 					j--
 				}
@@ -2,11 +2,13 @@ package rectpack
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 )
 
 // Heuristic is a bitfield used for configuration of a rectangle packing algorithm, including the
-// general type, bin selection method, and strategy for how to split empty areas. Specific
+// general type, bin selection method, strategy for how to split empty areas, and optional flags. Specific
 // combinations of values can be XOR'ed together to achieve the desired behavior.
 //
 // Note that not not all combinations are valid, each constant of this type will indicate what it
@@ -46,6 +48,24 @@ const (
 	// Type: Algorithm
 	Guillotine = 0x2
 
+	// Shelf selects the Shelf algorithm for packing, which places rectangles left-to-right in
+	// horizontal rows (shelves) that are stacked top-to-bottom. This is by far the fastest
+	// algorithm and produces row layouts that are easy to stream, such as for caching glyphs in
+	// real-time, at the cost of efficiency. Space above shorter rectangles in a shelf is wasted
+	// unless the WasteMap flag is used.
+	//
+	// Type: Algorithm
+	Shelf = 0x3
+
+	// BinaryTree selects the classic recursive binary tree algorithm for packing, which places
+	// each rectangle into the top-left corner of the first free node into which it fits, and
+	// splits the remainder into a node to its right and a node below it. The layouts it produces
+	// are predictable and easy to debug, such as for baking lightmaps. With the Grow flag, the
+	// tree starts at the size of the first rectangle and grows to the right or down as needed.
+	//
+	// Type: Algorithm
+	BinaryTree = 0x4
+
 	/**********************************************************************************************
 	* Bin-Selection
 	**********************************************************************************************/
@@ -63,9 +83,10 @@ const (
 	//	* Valid With: MaxRects, Guillotine
 	BestLongSideFit = 0x10
 	// BestAreaFit (BAF) positions the rectangle into the smallest free rect into which it fits.
+	// For Shelf, this is the shelf with the smallest remaining area.
 	//
 	//	* Type: Bin-Selection
-	//	* Valid With: MaxRects, Guillotine
+	//	* Valid With: MaxRects, Guillotine, Shelf
 	BestAreaFit = 0x20
 	// BottomLeft (BL) does the Tetris placement.
 	//
@@ -103,6 +124,35 @@ const (
 	//	* Type: Bin-Selection
 	//	* Valid With: Skyline
 	MinWaste = 0x80
+	// NextFit (NF) only considers the most recently opened shelf, opening a new shelf when the
+	// rectangle does not fit. This is the fastest method, but wastes the most space.
+	//
+	//	* Type: Bin-Selection
+	//	* Valid With: Shelf
+	NextFit = 0x90
+	// FirstFit (FF) places the rectangle onto the first shelf into which it fits.
+	//
+	//	* Type: Bin-Selection
+	//	* Valid With: Shelf
+	FirstFit = 0xA0
+	// BestWidthFit (BWF) places the rectangle onto the shelf with the least remaining width
+	// after placement.
+	//
+	//	* Type: Bin-Selection
+	//	* Valid With: Shelf
+	BestWidthFit = 0xB0
+	// BestHeightFit (BHF) places the rectangle onto the shelf whose height most closely matches
+	// the height of the rectangle.
+	//
+	//	* Type: Bin-Selection
+	//	* Valid With: Shelf
+	BestHeightFit = 0xC0
+	// WorstWidthFit (WWF) is the opposite of the BestWidthFit (BWF) heuristic, placing the
+	// rectangle onto the shelf with the most remaining width after placement.
+	//
+	//	* Type: Bin-Selection
+	//	* Valid With: Shelf
+	WorstWidthFit = 0xD0
 
 	/**********************************************************************************************
 	* Splitting algorithms (only used with guillotine algorithms)
@@ -145,6 +195,26 @@ const (
 	//	* Valid With: Guillotine
 	SplitLongerAxis = 0x0500
 
+	/**********************************************************************************************
+	* Flags (can be combined)
+	**********************************************************************************************/
+
+	// WasteMap (WM) records the space above shorter rectangles when a shelf is closed, and
+	// attempts to pack rectangles into it before opening a new shelf. This recovers much of the
+	// efficiency lost by shelf packing, at a small cost to speed.
+	//
+	//	* Type: Flag
+	//	* Valid With: Shelf
+	WasteMap = 0x1000
+
+	// Grow (GROW) starts with an empty tree and grows it to the right or down whenever a
+	// rectangle does not fit, keeping the result roughly square, up to the maximum size. This
+	// produces compact results without needing to choose a size up front.
+	//
+	//	* Type: Flag
+	//	* Valid With: BinaryTree
+	Grow = 0x2000
+
 	/**********************************************************************************************
 	* Masks for extracting relevant bits
 	**********************************************************************************************/
@@ -152,6 +222,7 @@ const (
 	typeMask  = 0x000F
 	fitMask   = 0x00F0
 	splitMask = 0x0F00
+	flagMask  = 0xF000
 
 	/**********************************************************************************************
 	* Present combinations of valid heuristics
@@ -221,6 +292,41 @@ const (
 	//
 	//	* Type: Preset
 	SkylineMinWaste = Skyline | MinWaste
+
+	// ShelfNextFit
+	//
+	//	* Type: Preset
+	ShelfNextFit = Shelf | NextFit
+
+	// ShelfFirstFit
+	//
+	//	* Type: Preset
+	ShelfFirstFit = Shelf | FirstFit
+
+	// ShelfBestAreaFit
+	//
+	//	* Type: Preset
+	ShelfBestAreaFit = Shelf | BestAreaFit
+
+	// ShelfBestWidthFit
+	//
+	//	* Type: Preset
+	ShelfBestWidthFit = Shelf | BestWidthFit
+
+	// ShelfBestHeightFit
+	//
+	//	* Type: Preset
+	ShelfBestHeightFit = Shelf | BestHeightFit
+
+	// ShelfWorstWidthFit
+	//
+	//	* Type: Preset
+	ShelfWorstWidthFit = Shelf | WorstWidthFit
+
+	// BinaryTreeGrow
+	//
+	//	* Type: Preset
+	BinaryTreeGrow = BinaryTree | Grow
 )
 
 // Algorithm returns the algorithm portion of the bitmask.
@@ -238,10 +344,16 @@ func (e Heuristic) Split() Heuristic {
 	return e & splitMask
 }
 
+// Flags returns the flags portion of the bitmask.
+func (e Heuristic) Flags() Heuristic {
+	return e & flagMask
+}
+
 var (
 	algoErr  = errors.New("invalid algorithm type specified")
 	splitErr = errors.New("split method heuristic is invalid for algorithm type and will be ignored")
 	binErr   = errors.New("bin method heuristic is invalid for algorithm type")
+	flagErr  = errors.New("flag heuristic is invalid for algorithm type and will be ignored")
 )
 
 // Validate tests whether the combination of heuristics are in good form. A value of nil is
@@ -252,6 +364,7 @@ var (
 func (e Heuristic) Validate() error {
 	bin := e & fitMask
 	split := e & splitMask
+	flags := e & flagMask
 
 	switch e & typeMask {
 	case MaxRects:
@@ -279,17 +392,61 @@ func (e Heuristic) Validate() error {
 			return splitErr
 		}
 		switch bin {
-		case BestShortSideFit, BottomLeft, ContactPoint, BestLongSideFit, BestAreaFit:
+		case BestShortSideFit, BestLongSideFit, BestAreaFit, WorstAreaFit, WorstShortSideFit, WorstLongSideFit:
 		default:
+			return binErr
+		}
+	case Shelf:
+		if split != 0 {
 			return splitErr
 		}
+		switch bin {
+		case NextFit, FirstFit, BestAreaFit, BestWidthFit, BestHeightFit, WorstWidthFit:
+		default:
+			return binErr
+		}
+	case BinaryTree:
+		if split != 0 {
+			return splitErr
+		}
+		if bin != 0 {
+			return binErr
+		}
 	default:
 		return algoErr
 	}
 
+	var allowed Heuristic
+	switch e & typeMask {
+	case Shelf:
+		allowed = WasteMap
+	case BinaryTree:
+		allowed = Grow
+	}
+	if flags&^allowed != 0 {
+		return flagErr
+	}
 	return nil
 }
 
+// validHeuristics returns every combination of heuristics that passes validation.
+func validHeuristics() []Heuristic {
+	var result []Heuristic
+	for algo := Heuristic(0); algo <= typeMask; algo++ {
+		for bin := Heuristic(0); bin <= fitMask; bin += 0x10 {
+			for split := Heuristic(0); split <= splitMask; split += 0x100 {
+				// Iterate with a wider type, as the flags occupy the highest bits.
+				for flags := 0; flags <= flagMask; flags += 0x1000 {
+					if heuristic := algo | bin | split | Heuristic(flags); heuristic.Validate() == nil {
+						result = append(result, heuristic)
+					}
+				}
+			}
+		}
+	}
+	return result
+}
+
 // String returns the string representation of the heuristic.
 func (e Heuristic) String() string {
 	var sb strings.Builder
@@ -300,21 +457,30 @@ func (e Heuristic) String() string {
 		sb.WriteString("MaxRects")
 	case Skyline:
 		sb.WriteString("Skyline")
+	case Shelf:
+		sb.WriteString("Shelf")
+	case BinaryTree:
+		// The binary tree has no bin-selection methods.
+		sb.WriteString("BinaryTree")
+		if e&Grow != 0 {
+			sb.WriteString("-GROW")
+		}
+		return sb.String()
 	case Guillotine:
 		sb.WriteString("Guillotine")
 		switch e & splitMask {
 		case SplitShorterLeftoverAxis:
-			split = "-SLAS"
+			split = "SLAS"
 		case SplitLongerLeftoverAxis:
-			split = "-LLAS"
+			split = "LLAS"
 		case SplitMinimizeArea:
-			split = "-MINAS"
+			split = "MINAS"
 		case SplitMaximizeArea:
-			split = "-MAXAS"
+			split = "MAXAS"
 		case SplitShorterAxis:
-			split = "-SAS"
+			split = "SAS"
 		case SplitLongerAxis:
-			split = "-LAS"
+			split = "LAS"
 		}
 	}
 
@@ -337,6 +503,16 @@ func (e Heuristic) String() string {
 		bin = "WLSF"
 	case MinWaste:
 		bin = "MW"
+	case NextFit:
+		bin = "NF"
+	case FirstFit:
+		bin = "FF"
+	case BestWidthFit:
+		bin = "BWF"
+	case BestHeightFit:
+		bin = "BHF"
+	case WorstWidthFit:
+		bin = "WWF"
 	}
 
 	if bin != "" {
@@ -350,7 +526,54 @@ func (e Heuristic) String() string {
 		sb.WriteRune('-')
 		sb.WriteString(split)
 	}
+
+	if e&WasteMap != 0 {
+		sb.WriteString("-WM")
+	}
 	return sb.String()
 }
 
+// ParseHeuristic returns the heuristic described by its string representation, such as
+// "MaxRects-BSSF" or "Guillotine-BAF-MINAS". The comparison is case-insensitive, and the
+// default split method of Guillotine heuristics may be omitted.
+func ParseHeuristic(s string) (Heuristic, error) {
+	for _, heuristic := range validHeuristics() {
+		name := heuristic.String()
+		if strings.EqualFold(s, name) {
+			return heuristic, nil
+		}
+		if heuristic&typeMask == Guillotine && heuristic&splitMask == SplitShorterLeftoverAxis {
+			if strings.EqualFold(s, strings.TrimSuffix(name, "-SLAS")) {
+				return heuristic, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("unknown heuristic %q", s)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface. Valid heuristics are encoded as
+// their string representation, and any others as a hexadecimal number.
+func (e Heuristic) MarshalText() ([]byte, error) {
+	if e.Validate() == nil {
+		return []byte(e.String()), nil
+	}
+	return []byte(fmt.Sprintf("0x%04X", uint16(e))), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface, accepting either a string
+// representation or a number.
+func (e *Heuristic) UnmarshalText(text []byte) error {
+	if value, err := strconv.ParseUint(string(text), 0, 16); err == nil {
+		*e = Heuristic(value)
+		return nil
+	}
+
+	heuristic, err := ParseHeuristic(string(text))
+	if err != nil {
+		return err
+	}
+	*e = heuristic
+	return nil
+}
+
 // vim: ts=4
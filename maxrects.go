@@ -2,7 +2,7 @@ package rectpack
 
 import "math"
 
-type heuristicFunc func(pack *maxRects, width, height int) (Rect, int, int)
+type heuristicFunc func(pack *maxRects, width, height int, opts SizeOptions) (Rect, int, int)
 
 type maxRects struct {
 	algorithmBase
@@ -36,12 +36,11 @@ func newMaxRects(width, height int, heuristic Heuristic) *maxRects {
 func (p *maxRects) Reset(width, height int) {
 	p.algorithmBase.Reset(width, height)
 	p.newFreeRects = p.newFreeRects[:0]
-	p.freeRects = p.freeRects[:0]
-	p.freeRects = append(p.freeRects, NewRect(0, 0, p.maxWidth, p.maxHeight))
+	p.rebuild(0)
 }
 
 func (p *maxRects) Insert(padding int, sizes ...Size) []Size {
-	for len(sizes) > 0 {
+	for len(sizes) > 0 && !p.canceled() {
 
 		var bestNode Rect
 		bestScore1 := math.MaxInt
@@ -50,13 +49,15 @@ func (p *maxRects) Insert(padding int, sizes ...Size) []Size {
 
 		for i, size := range sizes {
 
-			padSize(&size, padding)
-			newNode, score1, score2 := p.scoreRect(size.Width, size.Height)
+			p.padSize(&size, padding)
+			if p.observer != nil {
+				p.scoring = size.ID
+			}
+			newNode, score1, score2 := p.scoreRect(size.Width, size.Height, p.options(size.Options))
 			if score1 < bestScore1 || (score1 == bestScore1 && score2 < bestScore2) {
 				bestScore1 = score1
 				bestScore2 = score2
 				bestNode = newNode
-				bestNode.ID = size.ID
 				bestRectIndex = i
 			}
 		}
@@ -66,8 +67,8 @@ func (p *maxRects) Insert(padding int, sizes ...Size) []Size {
 		}
 
 		p.placeRect(bestNode)
-		unpadRect(&bestNode, padding)
-		p.packed = append(p.packed, bestNode)
+		unpadRect(&bestNode, sizes[bestRectIndex])
+		p.addPacked(bestNode)
 
 		last := len(sizes) - 1
 		sizes[bestRectIndex] = sizes[last]
@@ -76,8 +77,8 @@ func (p *maxRects) Insert(padding int, sizes ...Size) []Size {
 	return sizes
 }
 
-func (p *maxRects) scoreRect(width, height int) (Rect, int, int) {
-	newNode, score1, score2 := p.findNode(p, width, height)
+func (p *maxRects) scoreRect(width, height int, opts SizeOptions) (Rect, int, int) {
+	newNode, score1, score2 := p.findNode(p, width, height, opts)
 	if newNode.Height == 0 {
 		score1 = math.MaxInt
 		score2 = math.MaxInt
@@ -85,7 +86,53 @@ func (p *maxRects) scoreRect(width, height int) (Rect, int, int) {
 	return newNode, score1, score2
 }
 
+func (p *maxRects) Remove(padding, id int) bool {
+	rect, ok := p.remove(id)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(rect, padding)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+func (p *maxRects) Place(padding int, rects ...Rect) []Rect {
+	return p.place(padding, rects, p.splitFreeRects)
+}
+
+func (p *maxRects) Reserve(padding int, rects ...Rect) []Rect {
+	return p.reserve(padding, rects, p.splitFreeRects)
+}
+
+func (p *maxRects) Unreserve(padding int, rect Rect) bool {
+	reserved, ok := p.unreserve(rect)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(reserved, 0)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+// rebuild recomputes the free rectangles from scratch by splitting the entire area with each
+// of the packed rectangles.
+func (p *maxRects) rebuild(padding int) {
+	p.freeRects = append(p.freeRects[:0], NewRect(0, 0, p.maxWidth, p.maxHeight))
+	for _, cell := range p.occupied(padding) {
+		p.splitFreeRects(cell)
+	}
+}
+
 func (p *maxRects) placeRect(node Rect) {
+	p.splitFreeRects(node)
+	p.usedArea += node.Area()
+}
+
+func (p *maxRects) splitFreeRects(node Rect) {
 	for i := 0; i < len(p.freeRects); {
 		if p.splitFreeNode(&p.freeRects[i], &node) {
 			last := len(p.freeRects) - 1
@@ -96,32 +143,44 @@ func (p *maxRects) placeRect(node Rect) {
 		}
 	}
 	p.pruneFreeList()
-	p.usedArea += node.Area()
 }
 
-func findPositionBottomLeft(p *maxRects, width, height int) (Rect, int, int) {
+func findPositionBottomLeft(p *maxRects, width, height int, opts SizeOptions) (Rect, int, int) {
+	flip := p.canFlip(opts)
 	var bestNode Rect
 
 	bestY := math.MaxInt
 	bestX := math.MaxInt
 
-	for _, freeRect := range p.freeRects {
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		if opts.aligned() {
+			aligned := alignFree(*freeRect, opts)
+			freeRect = &aligned
+		}
 
 		// Try to place the rectangle in upright (non-flipped) orientation.
 		if freeRect.Width >= width && freeRect.Height >= height {
 			topSideY := freeRect.Y + height
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, width, height, false, topSideY, freeRect.X)
+			}
 			if topSideY < bestY || (topSideY == bestY && freeRect.X < bestX) {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestY = topSideY
 				bestX = freeRect.X
 			}
 		}
 
-		if p.allowFlip && freeRect.Width >= height && freeRect.Height >= width {
+		if flip && freeRect.Width >= height && freeRect.Height >= width {
 			topSideY := freeRect.Y + width
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, height, width, true, topSideY, freeRect.X)
+			}
 			if topSideY < bestY || (topSideY == bestY && freeRect.X < bestX) {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
@@ -136,12 +195,18 @@ func findPositionBottomLeft(p *maxRects, width, height int) (Rect, int, int) {
 	return bestNode, bestY, bestX
 }
 
-func findPositionBestShortSideFit(p *maxRects, width, height int) (Rect, int, int) {
+func findPositionBestShortSideFit(p *maxRects, width, height int, opts SizeOptions) (Rect, int, int) {
+	flip := p.canFlip(opts)
 	var bestNode Rect
 	bestShortSideFit := math.MaxInt
 	bestLongSideFit := math.MaxInt
 
-	for _, freeRect := range p.freeRects {
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		if opts.aligned() {
+			aligned := alignFree(*freeRect, opts)
+			freeRect = &aligned
+		}
 
 		// Try to place the rectangle in upright (non-flipped) orientation.
 		if freeRect.Width >= width && freeRect.Height >= height {
@@ -150,22 +215,29 @@ func findPositionBestShortSideFit(p *maxRects, width, height int) (Rect, int, in
 			leftoverVert := abs(freeRect.Height - height)
 			shortSideFit := min(leftoverHoriz, leftoverVert)
 			longSideFit := max(leftoverHoriz, leftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, width, height, false, shortSideFit, longSideFit)
+			}
 
 			if shortSideFit < bestShortSideFit || (shortSideFit == bestShortSideFit && longSideFit < bestLongSideFit) {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestShortSideFit = shortSideFit
 				bestLongSideFit = longSideFit
 			}
 		}
 
-		if p.allowFlip && freeRect.Width >= height && freeRect.Height >= width {
+		if flip && freeRect.Width >= height && freeRect.Height >= width {
 			flippedLeftoverHoriz := abs(freeRect.Width - height)
 			flippedLeftoverVert := abs(freeRect.Height - width)
 			flippedShortSideFit := min(flippedLeftoverHoriz, flippedLeftoverVert)
 			flippedLongSideFit := max(flippedLeftoverHoriz, flippedLeftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, height, width, true, flippedShortSideFit, flippedLongSideFit)
+			}
 
 			if flippedShortSideFit < bestShortSideFit || (flippedShortSideFit == bestShortSideFit && flippedLongSideFit < bestLongSideFit) {
 				bestNode.X = freeRect.X
@@ -182,12 +254,18 @@ func findPositionBestShortSideFit(p *maxRects, width, height int) (Rect, int, in
 	return bestNode, bestShortSideFit, bestLongSideFit
 }
 
-func findPositionBestLongSideFit(p *maxRects, width, height int) (Rect, int, int) {
+func findPositionBestLongSideFit(p *maxRects, width, height int, opts SizeOptions) (Rect, int, int) {
+	flip := p.canFlip(opts)
 	var bestNode Rect
 	bestShortSideFit := math.MaxInt
 	bestLongSideFit := math.MaxInt
 
-	for _, freeRect := range p.freeRects {
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		if opts.aligned() {
+			aligned := alignFree(*freeRect, opts)
+			freeRect = &aligned
+		}
 
 		// Try to place the rectangle in upright (non-flipped) orientation.
 		if freeRect.Width >= width && freeRect.Height >= height {
@@ -196,22 +274,29 @@ func findPositionBestLongSideFit(p *maxRects, width, height int) (Rect, int, int
 			leftoverVert := abs(freeRect.Height - height)
 			shortSideFit := min(leftoverHoriz, leftoverVert)
 			longSideFit := max(leftoverHoriz, leftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, width, height, false, longSideFit, shortSideFit)
+			}
 
 			if longSideFit < bestLongSideFit || (longSideFit == bestLongSideFit && shortSideFit < bestShortSideFit) {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestShortSideFit = shortSideFit
 				bestLongSideFit = longSideFit
 			}
 		}
 
-		if p.allowFlip && freeRect.Width >= height && freeRect.Height >= width {
+		if flip && freeRect.Width >= height && freeRect.Height >= width {
 			leftoverHoriz := abs(freeRect.Width - height)
 			leftoverVert := abs(freeRect.Height - width)
 			shortSideFit := min(leftoverHoriz, leftoverVert)
 			longSideFit := max(leftoverHoriz, leftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, height, width, true, longSideFit, shortSideFit)
+			}
 
 			if longSideFit < bestLongSideFit || (longSideFit == bestLongSideFit && shortSideFit < bestShortSideFit) {
 				bestNode.X = freeRect.X
@@ -227,13 +312,19 @@ func findPositionBestLongSideFit(p *maxRects, width, height int) (Rect, int, int
 	return bestNode, bestShortSideFit, bestLongSideFit
 }
 
-func findPositionBestAreaFit(p *maxRects, width, height int) (Rect, int, int) {
+func findPositionBestAreaFit(p *maxRects, width, height int, opts SizeOptions) (Rect, int, int) {
+	flip := p.canFlip(opts)
 	var bestNode Rect
 
 	bestAreaFit := math.MaxInt
 	bestShortSideFit := math.MaxInt
 
-	for _, freeRect := range p.freeRects {
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		if opts.aligned() {
+			aligned := alignFree(*freeRect, opts)
+			freeRect = &aligned
+		}
 		areaFit := freeRect.Width*freeRect.Height - width*height
 
 		// Try to place the rectangle in upright (non-flipped) orientation.
@@ -241,21 +332,28 @@ func findPositionBestAreaFit(p *maxRects, width, height int) (Rect, int, int) {
 			leftoverHoriz := abs(freeRect.Width - width)
 			leftoverVert := abs(freeRect.Height - height)
 			shortSideFit := min(leftoverHoriz, leftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, width, height, false, areaFit, shortSideFit)
+			}
 
 			if areaFit < bestAreaFit || (areaFit == bestAreaFit && shortSideFit < bestShortSideFit) {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestShortSideFit = shortSideFit
 				bestAreaFit = areaFit
 			}
 		}
 
-		if p.allowFlip && freeRect.Width >= height && freeRect.Height >= width {
+		if flip && freeRect.Width >= height && freeRect.Height >= width {
 			leftoverHoriz := abs(freeRect.Width - height)
 			leftoverVert := abs(freeRect.Height - width)
 			shortSideFit := min(leftoverHoriz, leftoverVert)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, height, width, true, areaFit, shortSideFit)
+			}
 
 			if areaFit < bestAreaFit || (areaFit == bestAreaFit && shortSideFit < bestShortSideFit) {
 				bestNode.X = freeRect.X
@@ -301,24 +399,37 @@ func (p *maxRects) contactPointScoreNode(x, y, width, height int) int {
 	return score
 }
 
-func findPositionContactPoint(p *maxRects, width, height int) (Rect, int, int) {
+func findPositionContactPoint(p *maxRects, width, height int, opts SizeOptions) (Rect, int, int) {
+	flip := p.canFlip(opts)
 	var bestNode Rect
 	bestContactScore := -1
 
-	for _, freeRect := range p.freeRects {
+	for i := range p.freeRects {
+		freeRect := &p.freeRects[i]
+		if opts.aligned() {
+			aligned := alignFree(*freeRect, opts)
+			freeRect = &aligned
+		}
 		// Try to place the rectangle in upright (non-flipped) orientation.
 		if freeRect.Width >= width && freeRect.Height >= height {
 			score := p.contactPointScoreNode(freeRect.X, freeRect.Y, width, height)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, width, height, false, score, 0)
+			}
 			if score > bestContactScore {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
 				bestNode.Width = width
 				bestNode.Height = height
+				bestNode.Flipped = false
 				bestContactScore = score
 			}
 		}
-		if p.allowFlip && freeRect.Width >= height && freeRect.Height >= width {
+		if flip && freeRect.Width >= height && freeRect.Height >= width {
 			score := p.contactPointScoreNode(freeRect.X, freeRect.Y, height, width)
+			if p.observer != nil {
+				p.observeScore(*freeRect, freeRect.X, freeRect.Y, height, width, true, score, 0)
+			}
 			if score > bestContactScore {
 				bestNode.X = freeRect.X
 				bestNode.Y = freeRect.Y
@@ -365,6 +476,8 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect) bool {
 	}
 
 	p.newLastSize = len(p.newFreeRects)
+	// The areas the free node is split into, which are only tracked when there is an observer.
+	var parts []Rect
 
 	if usedNode.X < freeNode.X+freeNode.Width && usedNode.X+usedNode.Width > freeNode.X {
 		// New node at the top side of the used node.
@@ -372,6 +485,9 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect) bool {
 			newNode := *freeNode
 			newNode.Height = usedNode.Y - newNode.Y
 			p.insertNewFreeRectangle(newNode)
+			if p.observer != nil {
+				parts = append(parts, newNode)
+			}
 		}
 
 		// New node at the bottom side of the used node.
@@ -380,6 +496,9 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect) bool {
 			newNode.Y = usedNode.Y + usedNode.Height
 			newNode.Height = freeNode.Y + freeNode.Height - (usedNode.Y + usedNode.Height)
 			p.insertNewFreeRectangle(newNode)
+			if p.observer != nil {
+				parts = append(parts, newNode)
+			}
 		}
 	}
 
@@ -389,6 +508,9 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect) bool {
 			newNode := *freeNode
 			newNode.Width = usedNode.X - newNode.X
 			p.insertNewFreeRectangle(newNode)
+			if p.observer != nil {
+				parts = append(parts, newNode)
+			}
 		}
 
 		// New node at the right side of the used node.
@@ -397,9 +519,15 @@ func (p *maxRects) splitFreeNode(freeNode, usedNode *Rect) bool {
 			newNode.X = usedNode.X + usedNode.Width
 			newNode.Width = freeNode.X + freeNode.Width - (usedNode.X + usedNode.Width)
 			p.insertNewFreeRectangle(newNode)
+			if p.observer != nil {
+				parts = append(parts, newNode)
+			}
 		}
 	}
 
+	if p.observer != nil {
+		p.observeSplit(*freeNode, *usedNode, parts)
+	}
 	return true
 }
 
@@ -409,7 +537,9 @@ func (p *maxRects) pruneFreeList() {
 		for j := 0; j < len(p.newFreeRects); {
 
 			if p.freeRects[i].ContainsRect(p.newFreeRects[j]) {
-
+				if p.observer != nil {
+					p.observePrune(p.newFreeRects[j], p.freeRects[i])
+				}
 				last := len(p.newFreeRects) - 1
 				p.newFreeRects[j] = p.newFreeRects[last]
 				p.newFreeRects = p.newFreeRects[:last]
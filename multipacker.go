@@ -0,0 +1,364 @@
+package rectpack
+
+import (
+	"cmp"
+	"context"
+	"slices"
+)
+
+// BinSelect describes the policy used by a MultiPacker to choose which of its open bins
+// rectangles are packed into before a new bin is opened.
+type BinSelect uint8
+
+const (
+	// BinFirstFit offers rectangles to each open bin in the order they were opened, placing them
+	// into the first bin they fit.
+	BinFirstFit BinSelect = iota
+	// BinBestFit offers rectangles to the open bins ordered from the most used to the least
+	// used, placing them into the fullest bin they fit.
+	BinBestFit
+	// BinLast only offers rectangles to the most recently opened bin. Once a new bin is opened,
+	// all previous bins are effectively closed.
+	BinLast
+)
+
+// MultiPacker packs rectangles into any number of bins of the same maximum size, opening new
+// bins as needed whenever the current ones are full. Each bin is a Packer sharing the same
+// heuristics, padding, and flip settings.
+type MultiPacker struct {
+	// bins contains the currently open bins, in the order they were opened.
+	bins []*Packer
+	// unpacked contains sizes that have not yet been packed or unable to be packed.
+	unpacked []Size
+	// aliases maps the ID of each alias to the ID of the rectangle it refers to.
+	aliases map[int]int
+	// heuristic is the configuration used when opening a new bin.
+	heuristic Heuristic
+	// maxWidth is the maximum width of each bin.
+	maxWidth int
+	// maxHeight is the maximum height of each bin.
+	maxHeight int
+	// sortFunc contains the function that will be used to determine comparison of sizes
+	// when sorting.
+	sortFunc SortFunc
+	// sortRev is flag indicating if reverse-ordering of rectangles during sorting should be
+	// enabled.
+	sortRev bool
+	// allowFlip indicates if rectangles can be flipped/rotated in newly opened bins.
+	allowFlip bool
+	// block is the size of the blocks rectangles are aligned to in newly opened bins.
+	block int
+	// Padding defines the amount of empty space to place around rectangles. Values of 0 or less
+	// indicates that rectangles will be tightly packed. See Packer.Padding for details.
+	//
+	// Default: 0
+	Padding int
+	// Online indicates if rectangles should be packed as they are inserted (online), or simply
+	// collected until Pack is called. See Packer.Online for a description of the trade-offs.
+	//
+	// Default: false
+	Online bool
+	// Select is the policy used to choose which open bin rectangles are packed into.
+	//
+	// Default: BinFirstFit
+	Select BinSelect
+	// MaxBins limits the number of bins that can be opened. Values of 0 or less indicates that
+	// there is no limit.
+	//
+	// Default: 0
+	MaxBins int
+}
+
+// NewMultiPacker initializes a new MultiPacker where each bin uses the specified maximum size
+// and heuristics for packing rectangles.
+func NewMultiPacker(maxWidth, maxHeight int, heuristic Heuristic) (*MultiPacker, error) {
+	// Ensure the configuration is valid up front instead of when the first bin is opened.
+	if _, err := NewAlgorithm(maxWidth, maxHeight, heuristic); err != nil {
+		return nil, err
+	}
+
+	p := &MultiPacker{
+		heuristic: heuristic,
+		maxWidth:  maxWidth,
+		maxHeight: maxHeight,
+		sortFunc:  SortArea,
+		Select:    BinFirstFit,
+	}
+	return p, nil
+}
+
+// Insert adds rectangles to the packer.
+//
+// When online mode is enabled, the rectangle(s) are immediately packed, opening new bins as
+// required. The return value will contain any values that could not be packed, or an empty
+// slice upon success.
+//
+// When online mode is disabled, the rectangles(s) are simply staged to be packed with the
+// next call to Pack. The return value will contain a slice of all rectangles that are currently
+// staged.
+func (p *MultiPacker) Insert(sizes ...Size) []Size {
+	if p.Online {
+		failed, _ := p.insert(context.Background(), sizes)
+		return failed
+	}
+
+	p.unpacked = append(p.unpacked, sizes...)
+	return p.unpacked
+}
+
+// InsertSize adds a rectangle to the packer. See Insert for details.
+//
+// Returns false when online mode is enabled and the rectangle could not be packed.
+func (p *MultiPacker) InsertSize(id, width, height int) bool {
+	result := p.Insert(NewSizeID(id, width, height))
+	if p.Online && len(result) != 0 {
+		return false
+	}
+	return true
+}
+
+// Pack will sort and pack all rectangles that are currently staged, opening new bins as
+// required.
+//
+// The return value indicates if all staged rectangles were successfully packed. When false,
+// Unpacked can be used to retrieve the sizes that failed, which is only possible when a size
+// exceeds the maximum size of a bin, or the MaxBins limit has been reached.
+func (p *MultiPacker) Pack() bool {
+	if len(p.unpacked) == 0 {
+		return true
+	}
+
+	sortSizes(p.unpacked, p.sortFunc, p.sortRev)
+	p.unpacked, _ = p.insert(context.Background(), p.unpacked)
+	return len(p.unpacked) == 0
+}
+
+// insert packs the sizes into the open bins following the selection policy, and then into new
+// bins until everything is packed, no more progress can be made, or the context is done. Returns
+// the sizes that could not be packed, along with the error of the context if it stopped packing.
+func (p *MultiPacker) insert(ctx context.Context, sizes []Size) ([]Size, error) {
+	var err error
+	for _, index := range p.openBins() {
+		if len(sizes) == 0 {
+			return sizes, nil
+		}
+		if sizes, err = p.insertBin(ctx, index, sizes); err != nil {
+			return sizes, err
+		}
+	}
+
+	for len(sizes) > 0 && (p.MaxBins <= 0 || len(p.bins) < p.MaxBins) {
+		algo, _ := NewAlgorithm(p.maxWidth, p.maxHeight, p.heuristic)
+		algo.AllowFlip(p.allowFlip)
+		algo.(blockAligner).setBlock(p.block)
+		p.bins = append(p.bins, &Packer{algo: algo, heuristic: p.heuristic, Padding: p.Padding})
+
+		count := len(sizes)
+		sizes, err = p.insertBin(ctx, len(p.bins)-1, sizes)
+
+		// When nothing fits into an empty bin, the remaining sizes are too large to ever be packed,
+		// unless the context was done before anything was tried.
+		if len(sizes) == count {
+			p.bins = p.bins[:len(p.bins)-1]
+			return sizes, err
+		}
+		if err != nil {
+			return sizes, err
+		}
+	}
+
+	return sizes, nil
+}
+
+// insertBin packs the sizes into the bin at the specified index, returning those that did
+// not fit, along with the error of the context if it stopped packing.
+func (p *MultiPacker) insertBin(ctx context.Context, index int, sizes []Size) ([]Size, error) {
+	bin := p.bins[index]
+	bin.Padding = p.Padding
+
+	start := len(bin.algo.Rects())
+	failed, err := insertContext(ctx, bin.algo, p.Padding, sizes)
+
+	rects := bin.algo.Rects()
+	for i := start; i < len(rects); i++ {
+		rects[i].Bin = index
+	}
+	return failed, err
+}
+
+// openBins returns the indices of the bins that can accept rectangles, in the order they
+// should be tried according to the selection policy.
+func (p *MultiPacker) openBins() []int {
+	if len(p.bins) == 0 {
+		return nil
+	}
+
+	switch p.Select {
+	case BinLast:
+		return []int{len(p.bins) - 1}
+	case BinBestFit:
+		indices := make([]int, len(p.bins))
+		for i := range indices {
+			indices[i] = i
+		}
+		slices.SortStableFunc(indices, func(a, b int) int {
+			return cmp.Compare(p.bins[b].algo.Used(), p.bins[a].algo.Used())
+		})
+		return indices
+	default: // BinFirstFit
+		indices := make([]int, len(p.bins))
+		for i := range indices {
+			indices[i] = i
+		}
+		return indices
+	}
+}
+
+// Sorter sets the comparer function used for pre-sorting sizes before packing. Depending on
+// the algorithm and the input data, this can provide a significant improvement on efficiency.
+//
+// Default: SortArea
+func (p *MultiPacker) Sorter(compare SortFunc, reverse bool) {
+	p.sortFunc = compare
+	p.sortRev = reverse
+}
+
+// AllowFlip indicates if rectangles can be flipped/rotated to provide better placement. The
+// setting is applied to all bins, including those that are currently open.
+//
+// Default: false
+func (p *MultiPacker) AllowFlip(enabled bool) {
+	p.allowFlip = enabled
+	for _, bin := range p.bins {
+		bin.AllowFlip(enabled)
+	}
+}
+
+// BlockAlign aligns rectangles to a grid of square blocks with the specified size in all bins,
+// as required by block-compressed texture formats. See Packer.BlockAlign for details.
+//
+// When the block size changes, any rectangles that are already packed are removed from their bins
+// and staged to be packed again, and all bins are closed.
+//
+// Default: 0
+func (p *MultiPacker) BlockAlign(size int) {
+	if size = max(size, 0); size == p.block {
+		return
+	}
+	p.block = size
+	for _, bin := range p.bins {
+		bin.collect()
+		p.unpacked = append(p.unpacked, bin.unpacked...)
+	}
+	p.bins = p.bins[:0]
+}
+
+// Bins returns a slice containing each bin that has been opened, where the index of each bin
+// corresponds to the Bin field of the rectangles packed into it.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *MultiPacker) Bins() []*Packer {
+	return p.bins
+}
+
+// Rects returns a new slice containing the rectangles that are currently packed in all bins.
+// The Bin field of each rectangle indicates which bin it was packed into.
+func (p *MultiPacker) Rects() []Rect {
+	var count int
+	for _, bin := range p.bins {
+		count += len(bin.algo.Rects())
+	}
+
+	rects := make([]Rect, 0, count)
+	for _, bin := range p.bins {
+		rects = append(rects, bin.algo.Rects()...)
+	}
+	return rects
+}
+
+// Remove deletes the first packed rectangle with the specified ID from whichever bin it is
+// packed in, returning its area to the free space of the bin. See Packer.Remove for details.
+//
+// Bins are never closed by removing rectangles, even when they become empty.
+//
+// Returns false if no rectangle with the ID is packed.
+func (p *MultiPacker) Remove(id int) bool {
+	for _, bin := range p.bins {
+		if bin.algo.Remove(p.Padding, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unpacked returns a slice of rectangles that are currently staged to be packed.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *MultiPacker) Unpacked() []Size {
+	return p.unpacked
+}
+
+// Used computes the ratio of used surface area to the available area of all open bins, in the
+// range of 0.0 and 1.0.
+//
+// When current is set to true, the ratio will reflect the ratio of used surface area relative
+// to the current size required by each bin, otherwise it is the ratio of the maximum possible
+// area.
+func (p *MultiPacker) Used(current bool) float64 {
+	var used, total int
+	for _, bin := range p.bins {
+		used += bin.algo.UsedArea()
+		if current {
+			size := bin.Size()
+			total += size.Area()
+		} else {
+			total += p.maxWidth * p.maxHeight
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(used) / float64(total)
+}
+
+// Map creates and returns a map where each key is an ID, and the value is the rectangle it
+// pertains to. The Bin field of each rectangle indicates which bin it was packed into. Aliases
+// are included with the rectangle they refer to when it is packed.
+func (p *MultiPacker) Map() map[int]Rect {
+	mapping := make(map[int]Rect)
+	for _, bin := range p.bins {
+		for _, rect := range bin.algo.Rects() {
+			mapping[rect.ID] = rect
+		}
+	}
+	mapAliases(mapping, p.aliases)
+	return mapping
+}
+
+// Alias adds an ID that refers to the same rectangle as the target ID, regardless of which bin
+// it is packed into. See Packer.Alias for details.
+func (p *MultiPacker) Alias(id, target int) {
+	addAlias(&p.aliases, id, target)
+}
+
+// Aliases returns a map where each key is the ID of an alias, and the value is the ID of the
+// rectangle it refers to.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *MultiPacker) Aliases() map[int]int {
+	return p.aliases
+}
+
+// Clear resets the internal state of the packer without changing its current configuration. All
+// bins are closed, and all currently packed and pending rectangles, and all aliases, are removed.
+func (p *MultiPacker) Clear() {
+	p.bins = p.bins[:0]
+	p.unpacked = p.unpacked[:0]
+	clear(p.aliases)
+}
+
+// vim: ts=4
@@ -0,0 +1,210 @@
+package rectpack
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EventKind describes the type of decision made by an algorithm.
+type EventKind uint8
+
+const (
+	// EventScore indicates that a free area was scored as a candidate location for a size. The
+	// Area field contains the free area, which is a free rectangle of the MaxRects and Guillotine
+	// algorithms, the area above the skyline node the search started from for the Skyline
+	// algorithm, the remaining space of a shelf for the Shelf algorithm, or a free leaf for the
+	// BinaryTree algorithm. The Rect field contains the candidate placement, including padding,
+	// and Score1 and Score2 contain the scores computed by the heuristic, where lower values are
+	// better unless noted otherwise by the heuristic.
+	EventScore EventKind = iota
+	// EventPlace indicates that a rectangle was packed. The Rect field contains the packed
+	// rectangle without padding.
+	EventPlace
+	// EventSplit indicates that a free area was divided by a placed rectangle. The Area field
+	// contains the free area, the Rect field contains the cell of the placed rectangle, and the
+	// Parts field contains the free areas that remain.
+	EventSplit
+	// EventMerge indicates that adjacent free areas were merged into one. The Parts field
+	// contains the areas that were merged, and the Rect field contains the result.
+	EventMerge
+	// EventPrune indicates that a free rectangle was discarded because it is contained within
+	// another. The Rect field contains the discarded rectangle, and the Area field contains the
+	// rectangle that contains it.
+	EventPrune
+)
+
+var eventNames = [...]string{
+	EventScore: "score",
+	EventPlace: "place",
+	EventSplit: "split",
+	EventMerge: "merge",
+	EventPrune: "prune",
+}
+
+// String returns the name of the event kind.
+func (e EventKind) String() string {
+	if int(e) < len(eventNames) {
+		return eventNames[e]
+	}
+	return fmt.Sprintf("EventKind(%d)", uint8(e))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (e EventKind) MarshalText() ([]byte, error) {
+	if int(e) >= len(eventNames) {
+		return nil, fmt.Errorf("invalid event kind: %d", uint8(e))
+	}
+	return []byte(eventNames[e]), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (e *EventKind) UnmarshalText(text []byte) error {
+	for kind, name := range eventNames {
+		if name == string(text) {
+			*e = EventKind(kind)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid event kind: %q", text)
+}
+
+// Event describes a single decision made by an algorithm while packing. The meaning of each field
+// depends upon the kind of event, see the EventKind constants for details.
+type Event struct {
+	// Kind is the type of the event.
+	Kind EventKind `json:"kind"`
+	// ID is the ID of the size of a scored or packed rectangle, which is also the ID of Rect. It
+	// is stored separately as the ID of a rectangle is not encoded as JSON.
+	ID int `json:"id,omitempty"`
+	// Rect is the rectangle the event concerns.
+	Rect Rect `json:"rect"`
+	// Area is the free area the event concerns.
+	Area Rect `json:"area"`
+	// Score1 is the primary score of a candidate placement.
+	Score1 int `json:"score1,omitempty"`
+	// Score2 is the secondary score of a candidate placement, which breaks ties of the primary
+	// score.
+	Score2 int `json:"score2,omitempty"`
+	// Parts contains the free areas produced by a split, or the areas that were merged.
+	Parts []Rect `json:"parts,omitempty"`
+}
+
+// Observer is the interface implemented by types that receive the decisions made by an algorithm
+// while packing, such as to trace or visualize how a layout came to be.
+//
+// Events are delivered synchronously while the algorithm is running, so the observer must not
+// modify the packer, and any rectangles it retains must be copied.
+type Observer interface {
+	// Observe is called for each event in the order they occur.
+	Observe(event Event)
+}
+
+// ObserverFunc is an adapter to allow the use of an ordinary function as an Observer.
+type ObserverFunc func(event Event)
+
+// Observe calls f(event).
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// observable is implemented by algorithms that support notifying an Observer, which is all of the
+// built-in algorithms.
+type observable interface {
+	// setObserver sets the observer to notify, or nil to remove it.
+	setObserver(observer Observer)
+}
+
+// Observe installs an observer that receives every decision the algorithm makes while packing,
+// from the scoring of each candidate location to the division and merging of free space. Passing
+// nil removes it. There is no overhead when no observer is installed.
+//
+// Returns false if the algorithm does not support it, which is only the case for custom
+// algorithms.
+func (p *Packer) Observe(observer Observer) bool {
+	algo, ok := p.algo.(observable)
+	if ok {
+		algo.setObserver(observer)
+	}
+	return ok
+}
+
+func (p *algorithmBase) setObserver(observer Observer) {
+	p.observer = observer
+}
+
+// The following functions notify the observer of an event. Callers must first test that there is
+// an observer, so that nothing is computed for the event when there is none.
+
+// observeScore notifies the observer that a candidate location for the size being scored was
+// found within a free area.
+func (p *algorithmBase) observeScore(area Rect, x, y, width, height int, flipped bool, score1, score2 int) {
+	rect := NewRect(x, y, width, height)
+	rect.ID = p.scoring
+	rect.Flipped = flipped
+	p.observer.Observe(Event{Kind: EventScore, ID: rect.ID, Rect: rect, Area: area, Score1: score1, Score2: score2})
+}
+
+// observeSplit notifies the observer that a free area was divided by a cell.
+func (p *algorithmBase) observeSplit(area, cell Rect, parts []Rect) {
+	p.observer.Observe(Event{Kind: EventSplit, Rect: cell, Area: area, Parts: parts})
+}
+
+// observeMerge notifies the observer that two adjacent free areas are being merged.
+func (p *algorithmBase) observeMerge(a, b Rect) {
+	p.observer.Observe(Event{Kind: EventMerge, Rect: a.Union(b), Parts: []Rect{a, b}})
+}
+
+// observePrune notifies the observer that a free rectangle was discarded because it is contained
+// by another.
+func (p *algorithmBase) observePrune(rect, container Rect) {
+	p.observer.Observe(Event{Kind: EventPrune, Rect: rect, Area: container})
+}
+
+// Recorder is an Observer that writes each event to a trace as a line of JSON, which can be read
+// back with Replay.
+type Recorder struct {
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder creates a recorder that writes a trace to the writer. Writes are not buffered, so
+// the writer should be buffered when tracing large packs.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{encoder: json.NewEncoder(w)}
+}
+
+// Observe writes the event to the trace. Once a write fails, all further events are discarded.
+func (r *Recorder) Observe(event Event) {
+	if r.err == nil {
+		r.err = r.encoder.Encode(&event)
+	}
+}
+
+// Err returns the first error that occurred while writing the trace, if any.
+func (r *Recorder) Err() error {
+	return r.err
+}
+
+// Replay reads a trace written by a Recorder, delivering each event to the observer in the order
+// they were recorded.
+func Replay(r io.Reader, observer Observer) error {
+	scanner := bufio.NewScanner(r)
+	// Split events can list many free rectangles.
+	scanner.Buffer(nil, 1<<24)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("trace line %d: %w", line, err)
+		}
+		event.Rect.ID = event.ID
+		observer.Observe(event)
+	}
+	return scanner.Err()
+}
+
+// vim: ts=4
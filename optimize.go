@@ -0,0 +1,270 @@
+package rectpack
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// defaultIterations is the number of candidates evaluated by Optimize when neither an iteration
+// count or time budget is specified.
+const defaultIterations = 1000
+
+// OptimizeOptions contains the settings used by Optimize when packing each candidate.
+type OptimizeOptions struct {
+	// Heuristic is the heuristics used to pack each candidate.
+	//
+	// Default: MaxRectsBSSF
+	Heuristic Heuristic
+	// Objective is the criteria used to compare candidates.
+	//
+	// Default: ObjectiveBins
+	Objective Objective
+	// Padding defines the amount of empty space to place around rectangles.
+	//
+	// Default: 0
+	Padding int
+	// AllowFlip indicates if rectangles can be flipped/rotated. When enabled, the rotation of each
+	// rectangle is optimized along with the insertion order, and the algorithm itself is still
+	// free to flip rectangles for better placement.
+	//
+	// Default: false
+	AllowFlip bool
+	// Select is the policy used to choose which open bin rectangles are packed into.
+	//
+	// Default: BinFirstFit
+	Select BinSelect
+	// MaxBins limits the number of bins that can be opened. Values of 0 or less indicates that
+	// there is no limit.
+	//
+	// Default: 0
+	MaxBins int
+	// Iterations is the maximum number of candidates that are evaluated. Values of 0 or less
+	// indicates that there is no limit, unless Duration is also 0 or less, in which case
+	// defaultIterations is used.
+	//
+	// Default: 0
+	Iterations int
+	// Duration is the maximum amount of time to spend searching. Values of 0 or less indicates
+	// that there is no limit. As the number of candidates evaluated within the duration depends
+	// on the speed of the machine, results are only reproducible when limited by Iterations.
+	//
+	// Default: 0
+	Duration time.Duration
+	// Seed initializes the random number generator, such that searches with the same seed and
+	// iteration count produce identical results.
+	//
+	// Default: 0
+	Seed int64
+}
+
+// OptimizeResult describes the best candidate found by Optimize.
+type OptimizeResult struct {
+	// Order contains the sizes in the order they were packed. Sizes that were rotated by the
+	// search have their width and height swapped, so inserting them in this order without
+	// sorting reproduces the result.
+	Order []Size
+	// Iterations is the number of candidates that were evaluated.
+	Iterations int
+	// Packer contains the packed result. Any sizes that could not be packed are staged and can
+	// be retrieved with Unpacked. Packed rectangles are flipped relative to the original sizes.
+	Packer *MultiPacker
+}
+
+// optimizeState is a candidate of the search, which is the insertion order of the sizes and
+// whether each is rotated.
+type optimizeState struct {
+	order   []int
+	rotated []bool
+	packer  *MultiPacker
+	energy  float64
+}
+
+// Optimize searches for the insertion order and rotation of the sizes that performs best for
+// the objective using simulated annealing. The search begins with the sizes sorted by SortArea,
+// and repeatedly evaluates a small change to the current candidate, such as swapping two sizes
+// or rotating one. Worse candidates are occasionally accepted to escape local optima, with
+// decreasing likelihood as the search progresses. Results that are able to pack more rectangles
+// are always preferred.
+//
+// The search stops when the iteration count or duration is reached, or when the context is
+// done. The best result found is always returned, along with the error of the context if it
+// stopped the search early.
+func Optimize(ctx context.Context, maxWidth, maxHeight int, sizes []Size, opts OptimizeOptions) (*OptimizeResult, error) {
+	if _, err := NewMultiPacker(maxWidth, maxHeight, opts.Heuristic); err != nil {
+		return nil, err
+	}
+
+	iterations := opts.Iterations
+	if iterations <= 0 && opts.Duration <= 0 {
+		iterations = defaultIterations
+	}
+	var deadline time.Time
+	if opts.Duration > 0 {
+		deadline = time.Now().Add(opts.Duration)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	binArea := float64(maxWidth) * float64(maxHeight)
+	evaluate := func(state *optimizeState) {
+		order := make([]Size, len(state.order))
+		for i, index := range state.order {
+			order[i] = sizes[index]
+			order[i].ID = index
+			if state.rotated[index] {
+				order[i].Width, order[i].Height = order[i].Height, order[i].Width
+			}
+		}
+
+		packer, _ := NewMultiPacker(maxWidth, maxHeight, opts.Heuristic)
+		packer.Padding = opts.Padding
+		packer.Select = opts.Select
+		packer.MaxBins = opts.MaxBins
+		packer.AllowFlip(opts.AllowFlip)
+		packer.Sorter(nil, false)
+		packer.Insert(order...)
+		packer.Pack()
+		state.packer = packer
+		state.energy = energy(packer, opts.Objective, binArea, len(sizes))
+	}
+
+	// Sizes that cannot be flipped are never rotated by the search.
+	var flippable []int
+	for i, size := range sizes {
+		if opts.AllowFlip && !size.Options.NoFlip {
+			flippable = append(flippable, i)
+		}
+	}
+
+	current := &optimizeState{order: make([]int, len(sizes)), rotated: make([]bool, len(sizes))}
+	for i := range current.order {
+		current.order[i] = i
+	}
+	slices.SortStableFunc(current.order, func(a, b int) int { return SortArea(sizes[a], sizes[b]) })
+	evaluate(current)
+	best := current
+
+	const startTemp, endTemp = 0.05, 0.0001
+	start := time.Now()
+	count := 1
+	var err error
+	for len(sizes) > 1 || len(flippable) > 0 {
+		if iterations > 0 && count >= iterations {
+			break
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			break
+		}
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		// The temperature decreases geometrically with the progress towards whichever limit is
+		// reached first.
+		progress := 0.0
+		if iterations > 0 {
+			progress = float64(count) / float64(iterations)
+		}
+		if !deadline.IsZero() {
+			progress = max(progress, float64(time.Since(start))/float64(opts.Duration))
+		}
+		temp := startTemp * math.Pow(endTemp/startTemp, min(progress, 1))
+
+		next := neighbor(rng, current, flippable)
+		evaluate(next)
+		count++
+
+		if delta := next.energy - current.energy; delta <= 0 || rng.Float64() < math.Exp(-delta/temp) {
+			current = next
+		}
+		if compareResults(next.packer, best.packer, opts.Objective) < 0 {
+			best = next
+		}
+	}
+
+	result := &OptimizeResult{Order: make([]Size, len(sizes)), Iterations: count, Packer: best.packer}
+	for i, index := range best.order {
+		result.Order[i] = sizes[index]
+		if best.rotated[index] {
+			result.Order[i].Width, result.Order[i].Height = sizes[index].Height, sizes[index].Width
+		}
+	}
+
+	// Restore the original IDs, which were replaced with indices to track rotation.
+	for _, bin := range result.Packer.bins {
+		rects := bin.algo.Rects()
+		for i := range rects {
+			index := rects[i].ID
+			rects[i].ID = sizes[index].ID
+			rects[i].Flipped = rects[i].Flipped != best.rotated[index]
+		}
+	}
+	for i, size := range result.Packer.unpacked {
+		result.Packer.unpacked[i] = sizes[size.ID]
+	}
+	result.Packer.Sorter(SortArea, false)
+	return result, err
+}
+
+// neighbor returns a copy of the state with a small random change applied, which either swaps
+// two sizes, moves a size to a different position, or rotates one of the flippable sizes, which
+// are specified by their index.
+func neighbor(rng *rand.Rand, state *optimizeState, flippable []int) *optimizeState {
+	next := &optimizeState{order: slices.Clone(state.order), rotated: slices.Clone(state.rotated)}
+	n := len(next.order)
+	rotate := func() {
+		index := flippable[rng.Intn(len(flippable))]
+		next.rotated[index] = !next.rotated[index]
+	}
+
+	if n < 2 {
+		// Only rotation is possible with a single size.
+		rotate()
+		return next
+	}
+
+	moves := 2
+	if len(flippable) > 0 {
+		moves++
+	}
+
+	i, j := rng.Intn(n), rng.Intn(n-1)
+	if j >= i {
+		j++
+	}
+	switch rng.Intn(moves) {
+	case 0:
+		next.order[i], next.order[j] = next.order[j], next.order[i]
+	case 1:
+		index := next.order[i]
+		next.order = slices.Insert(slices.Delete(next.order, i, i+1), j, index)
+	default:
+		rotate()
+	}
+	return next
+}
+
+// energy returns a value describing the quality of a result for the objective, where lower
+// values are better. Unlike compareResults, the magnitude of the difference between results is
+// meaningful, which is required to determine the likelihood of accepting a worse candidate.
+func energy(p *MultiPacker, objective Objective, binArea float64, count int) float64 {
+	// Each unpacked size outweighs any possible difference in the objective.
+	value := float64(len(p.Unpacked())) * float64(count+2)
+	bins := len(p.Bins())
+	if bins == 0 {
+		return value
+	}
+
+	switch objective {
+	case ObjectiveArea:
+		return value + float64(totalArea(p))/binArea
+	case ObjectiveUsed:
+		return value + 1 - p.Used(true)
+	default: // ObjectiveBins
+		return value + float64(bins) + float64(totalArea(p))/(binArea*float64(bins))
+	}
+}
+
+// vim: ts=4
@@ -3,7 +3,6 @@ package rectpack
 import (
 	"errors"
 	"fmt"
-	"slices"
 )
 
 // DefaultSize is the default width/height used as the maximum extent for packing rectangles.
@@ -18,12 +17,23 @@ type Packer struct {
 	// unpacked contains sizes that have not yet been packed or unable to be packed.
 	unpacked []Size
 	// algo is the algorithm implementation that performs the actual computation.
-	algo packAlgorithm
+	algo Algorithm
+	// heuristic is the configuration the algorithm was created with.
+	heuristic Heuristic
+	// aliases maps the ID of each alias to the ID of the rectangle it refers to.
+	aliases map[int]int
 	// sortFunc contains the function that will be used to determine comparison of sizes
 	// when sorting.
 	sortFunc SortFunc
 	// Padding defines the amount of empty space to place around rectangles. Values of 0 or less
-	// indicates that rectangles will be tightly packed.
+	// indicates that rectangles will be tightly packed. Individual sizes can override it with
+	// SizeOptions.Padding.
+	//
+	// The space is added to the right and bottom edges of each rectangle only, which leaves at
+	// least that much space between any two rectangles. Rectangles keep their size and may touch
+	// the left and top edges of the bin, and Size includes the padding of those on the right and
+	// bottom edges. Earlier versions also inset rectangles on the left and top edges of the bin,
+	// shrinking them by the padding, which is no longer the case.
 	//
 	// Default: 0
 	Padding int
@@ -52,13 +62,23 @@ type Packer struct {
 }
 
 // Size computes the size of the current packing. The returned value is the minimum size required
-// to contain all packed rectangles.
+// to contain all packed and reserved rectangles.
 func (p *Packer) Size() Size {
 	var size Size
 	for _, rect := range p.algo.Rects() {
-		size.Width = max(size.Width, rect.Right()+p.Padding)
-		size.Height = max(size.Height, rect.Bottom()+p.Padding)
+		padding := rect.Options.padding(p.Padding)
+		size.Width = max(size.Width, rect.Right()+padding)
+		size.Height = max(size.Height, rect.Bottom()+padding)
+	}
+	for _, rect := range p.algo.Reserved() {
+		size.Width = max(size.Width, rect.Right())
+		size.Height = max(size.Height, rect.Bottom())
 	}
+
+	// Partial blocks cannot be used, so include the whole of each block on the edges.
+	block := p.blockSize()
+	size.Width = alignUp(size.Width, block)
+	size.Height = alignUp(size.Height, block)
 	return size
 }
 
@@ -114,6 +134,64 @@ func (p *Packer) Rects() []Rect {
 	return p.algo.Rects()
 }
 
+// Remove deletes the first packed rectangle with the specified ID, returning its area to the
+// free space of the packer so that it can be reused by subsequent packing. Any rectangles that
+// are staged to be packed are not affected.
+//
+// Depending on the algorithm, not all of the area may be reclaimed. The Skyline algorithm can
+// only reclaim space that is not beneath another rectangle unless it uses a waste map.
+//
+// Returns false if no rectangle with the ID is packed.
+func (p *Packer) Remove(id int) bool {
+	return p.algo.Remove(p.Padding, id)
+}
+
+// Place adds rectangles at their specified locations as if they had been packed, so that
+// subsequent packing treats the area they occupy as used. This allows an existing layout to
+// be restored and extended without moving any of its rectangles.
+//
+// The padding of the packer is applied to the rectangles in the same way as if they had been
+// packed, so it should be configured beforehand.
+//
+// Returns a slice of rectangles that could not be placed because they are outside of the
+// maximum extents, or overlap a rectangle that is already packed.
+func (p *Packer) Place(rects ...Rect) []Rect {
+	return p.algo.Place(p.Padding, rects...)
+}
+
+// Reserve marks areas of the bin as occupied, so that no rectangles will be packed into them.
+// This can be used for fixed regions of a texture, such as a white pixel for untextured drawing.
+// Reservations can be made before or between packs, and are retained when the packer is
+// cleared or repacked until they are removed with Unreserve.
+//
+// Reserved rectangles are not included in Rects, though their area is included in Used. Unlike
+// packed rectangles, the padding of the packer is not applied to them, so any desired margin
+// should be included in the rectangle.
+//
+// With the Skyline heuristics, the area beneath a reserved rectangle can only be used when the
+// waste map is enabled.
+//
+// Returns a slice of rectangles that could not be reserved because they are outside of the
+// maximum extents, or overlap a rectangle that is already packed or reserved, including its
+// padding.
+func (p *Packer) Reserve(rects ...Rect) []Rect {
+	return p.algo.Reserve(p.Padding, rects...)
+}
+
+// Unreserve deletes the first reserved rectangle with the same location and size as the
+// specified rectangle, returning its area to the free space so that rectangles can be packed
+// into it.
+//
+// Returns false if no such rectangle is reserved.
+func (p *Packer) Unreserve(rect Rect) bool {
+	return p.algo.Unreserve(p.Padding, rect)
+}
+
+// Reserved returns a slice of rectangles that have been reserved.
+func (p *Packer) Reserved() []Rect {
+	return p.algo.Reserved()
+}
+
 // Unpacked returns a slice of rectangles that are currently staged to be packed.
 //
 // The backing memory is owned by the packer, and a copy should be made if modification or
@@ -131,29 +209,82 @@ func (p *Packer) Unpacked() []Size {
 func (p *Packer) Used(current bool) float64 {
 	if current {
 		size := p.Size()
-		return float64(p.algo.UsedArea()) / float64(size.Width * size.Height)
+		return float64(p.algo.UsedArea()) / float64(size.Width*size.Height)
 	}
 	return p.algo.Used()
 }
 
 // Map creates and returns a map where each key is an ID, and the value is the rectangle it
-// pertains to.
+// pertains to. Aliases are included with the rectangle they refer to when it is packed.
 func (p *Packer) Map() map[int]Rect {
 	rects := p.algo.Rects()
-	mapping := make(map[int]Rect, len(rects))
+	mapping := make(map[int]Rect, len(rects)+len(p.aliases))
 	for _, rect := range rects {
 		mapping[rect.ID] = rect
 	}
 
+	mapAliases(mapping, p.aliases)
 	return mapping
 }
 
+// Alias adds an ID that refers to the same rectangle as the target ID, allowing identical sizes
+// to share a single rectangle instead of each being packed. The alias is included in the result
+// of Map with the rectangle of the target, including its ID, whenever the target is packed.
+//
+// When the target is itself an alias, the new alias refers to the same rectangle that it does.
+// Adding an alias with an existing ID replaces it.
+func (p *Packer) Alias(id, target int) {
+	addAlias(&p.aliases, id, target)
+}
+
+// Aliases returns a map where each key is the ID of an alias, and the value is the ID of the
+// rectangle it refers to.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *Packer) Aliases() map[int]int {
+	return p.aliases
+}
+
+// addAlias adds an alias to the map, resolving the target if it is itself an alias. The map is
+// created if it is nil.
+func addAlias(aliases *map[int]int, id, target int) {
+	if *aliases == nil {
+		*aliases = make(map[int]int)
+	}
+	if resolved, ok := (*aliases)[target]; ok {
+		target = resolved
+	}
+	if id == target {
+		return
+	}
+
+	// Any aliases of the ID now refer to its target instead, so that no alias refers to another.
+	for alias, other := range *aliases {
+		if other == id {
+			(*aliases)[alias] = target
+		}
+	}
+	(*aliases)[id] = target
+}
+
+// mapAliases adds an entry for each alias whose target is in the map.
+func mapAliases(mapping map[int]Rect, aliases map[int]int) {
+	for id, target := range aliases {
+		if rect, ok := mapping[target]; ok {
+			mapping[id] = rect
+		}
+	}
+}
+
 // Clear resets the internal state of the packer without changing its current configuration. All
-// currently packed and pending rectangles are removed.
+// currently packed and pending rectangles, and all aliases, are removed. Reserved rectangles are
+// retained, and can be removed with Unreserve.
 func (p *Packer) Clear() {
 	size := p.algo.MaxSize()
 	p.algo.Reset(size.Width, size.Height)
 	p.unpacked = p.unpacked[:0]
+	clear(p.aliases)
 }
 
 // Pack will sort and pack all rectangles that are currently staged.
@@ -165,18 +296,7 @@ func (p *Packer) Pack() bool {
 		return true
 	}
 
-	if p.sortFunc != nil {
-		if p.sortRev {
-			slices.SortFunc(p.unpacked, func(a, b Size) int {
-				return p.sortFunc(b, a)
-			})
-		} else {
-			slices.SortFunc(p.unpacked, p.sortFunc)
-		}
-	} else if p.sortRev {
-		slices.Reverse(p.unpacked)
-	}
-
+	sortSizes(p.unpacked, p.sortFunc, p.sortRev)
 	failed := p.algo.Insert(p.Padding, p.unpacked...)
 	if len(failed) == 0 {
 		p.unpacked = p.unpacked[:0]
@@ -189,52 +309,129 @@ func (p *Packer) Pack() bool {
 
 // RepackAll clears the internal packed rectangles, and repacks them all with one operation. This
 // can be useful to optimize the packing when/if it was previously performed in multiple pack
-// operations, or to reflect settings for the packer that have been modified. 
+// operations, or to reflect settings for the packer that have been modified.
 func (p *Packer) RepackAll() bool {
-	rects := p.algo.Rects()
-	for _, rect := range rects {
-		p.unpacked = append(p.unpacked, rect.Size)
-	}
-	
-	size := p.Size()
+	p.collect()
+	size := p.algo.MaxSize()
 	p.algo.Reset(size.Width, size.Height)
 	return p.Pack()
 }
 
+// collect appends all packed rectangles to the staged sizes, restoring their original
+// orientation if they were flipped.
+func (p *Packer) collect() {
+	for _, rect := range p.algo.Rects() {
+		size := rect.Size
+		if rect.Flipped {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		p.unpacked = append(p.unpacked, size)
+	}
+}
+
 // AllowFlip indicates if rectangles can be flipped/rotated to provide better placement.
+// Individual sizes can prevent it with SizeOptions.NoFlip.
 //
 // Default: false
 func (p *Packer) AllowFlip(enabled bool) {
 	p.algo.AllowFlip(enabled)
 }
 
+// BlockAlign aligns rectangles to a grid of square blocks with the specified size, as required
+// by block-compressed texture formats such as BCn and ETC (4x4), or ASTC (up to 12x12). Each
+// rectangle is placed on a block boundary, and the area it occupies including its padding is
+// rounded up to a multiple of the block size, so that no block contains more than one rectangle.
+// The packed rectangles still report their original size. The size returned by Size, and the
+// sizes chosen by PackAuto, are also rounded up to a multiple of the block size.
+//
+// The maximum size should be a multiple of the block size, otherwise the partial blocks at the
+// right and bottom edges are left unused. Values of 1 or less disable alignment. When the block
+// size changes, any rectangles that are already packed are staged to be packed again.
+//
+// Returns false if the algorithm does not support block alignment, which is only the case for
+// custom algorithms.
+//
+// Default: 0
+func (p *Packer) BlockAlign(size int) bool {
+	algo, ok := p.algo.(blockAligner)
+	if !ok {
+		return false
+	}
+	if size = max(size, 0); size == algo.blockSize() {
+		return true
+	}
+
+	p.collect()
+	algo.setBlock(size)
+	maxSize := p.algo.MaxSize()
+	p.algo.Reset(maxSize.Width, maxSize.Height)
+	return true
+}
+
+// blockSize returns the size of the blocks that rectangles are aligned to, or 0 if the algorithm
+// does not support alignment.
+func (p *Packer) blockSize() int {
+	if algo, ok := p.algo.(blockAligner); ok {
+		return algo.blockSize()
+	}
+	return 0
+}
+
 // NewPacker initializes a new Packer using the specified maximum size and heustistics for
 // packing rectangles.
 //
-// A width/height less than 1 will cause a panic, 
+// A width/height less than 1 will cause a panic,
 func NewPacker(maxWidth, maxHeight int, heuristic Heuristic) (*Packer, error) {
-	if maxWidth <= 0 || maxHeight <= 0 {
-		return nil, fmt.Errorf("width and height must be greater than 0 (given %vx%x)", maxWidth, maxHeight)
+	algo, err := NewAlgorithm(maxWidth, maxHeight, heuristic)
+	if err != nil {
+		return nil, err
 	}
 
 	p := &Packer{
-		Online:   false,
-		sortFunc: SortArea,
-		sortRev:  false,
+		algo:      algo,
+		heuristic: heuristic,
+		Online:    false,
+		sortFunc:  SortArea,
+		sortRev:   false,
+	}
+
+	return p, nil
+}
+
+// NewAlgorithm creates the built-in algorithm implementation described by the heuristics,
+// initialized with the specified maximum size. This is typically not needed, but can be useful to
+// wrap or compose the built-in algorithms when implementing a custom Algorithm.
+func NewAlgorithm(maxWidth, maxHeight int, heuristic Heuristic) (Algorithm, error) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		return nil, fmt.Errorf("width and height must be greater than 0 (given %vx%v)", maxWidth, maxHeight)
 	}
 
 	switch heuristic & typeMask {
 	case MaxRects:
-		p.algo = newMaxRects(maxWidth, maxHeight, heuristic)
+		return newMaxRects(maxWidth, maxHeight, heuristic), nil
 	case Skyline:
-		p.algo = newSkyline(maxWidth, maxHeight, heuristic)
+		return newSkyline(maxWidth, maxHeight, heuristic), nil
 	case Guillotine:
-		p.algo = newGuillotine(maxWidth, maxHeight, heuristic)
+		return newGuillotine(maxWidth, maxHeight, heuristic), nil
+	case Shelf:
+		return newShelf(maxWidth, maxHeight, heuristic), nil
+	case BinaryTree:
+		return newTree(maxWidth, maxHeight, heuristic), nil
 	default:
 		return nil, errors.New("heuristics specify an invalid argorithm")
 	}
+}
 
-	return p, nil
+// NewAlgorithmPacker initializes a new Packer that uses a custom algorithm implementation for
+// packing rectangles, while providing the same sorting, padding, and online/offline behavior as
+// the built-in algorithms.
+//
+// Packers using a custom algorithm cannot be saved with MarshalJSON or MarshalBinary.
+func NewAlgorithmPacker(algo Algorithm) *Packer {
+	return &Packer{
+		algo:     algo,
+		sortFunc: SortArea,
+	}
 }
 
 // NewDefaultPacker initializes a new Packer with sensible default settings suitable for
@@ -1,88 +1,27 @@
 package rectpack
 
 import (
-	"fmt"
+	"bytes"
+	"context"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
-	"log"
+	"maps"
 	"math/rand"
 	"os"
-	"path/filepath"
+	"slices"
 	"testing"
 )
 
-func createAtlas(p *Packer, paths []string) (*image.RGBA, map[string]Rect, error) {
-	// Reset the packer to its initial state
-	p.Clear()
-
-	// Enumerate each given path and decode its header to retrieve the size
-	for i, path := range paths {
-		var cfg image.Config
-		if file, err := os.Open(path); err != nil {
-			return nil, nil, err
-		} else {
-			cfg, _, err = image.DecodeConfig(file)
-			file.Close()
-			if err != nil {
-				return nil, nil, err
-			}
-		}
-
-		// Insert the size into the packer, using the index as the ID
-		if !p.InsertSize(i, cfg.Width, cfg.Height) && p.Online {
-			// If packing in online mode, ensure each image fits as it is inserted.
-			size := p.Size()
-			return nil, nil, fmt.Errorf("cannot fit all images into size of %s", size.String())
-		}
-	}
-
-	// If packing in offline mode, perform tha packing and ensure all images were packed.
-	if !p.Online && !p.Pack() {
-		size := p.Size()
-		return nil, nil, fmt.Errorf("cannot fit all images into size of %s", size.String())
-	}
-
-	// Get the final required size of the atlas (includes any configured padding), and create
-	// a new image of that size to draw onto.
-	size := p.Size()
-	mapping := make(map[string]Rect, len(paths))
-	dst := image.NewRGBA(image.Rect(0, 0, size.Width, size.Height))
-	var zero image.Point
-
-	// Iterate through each packed rectangle.
-	for _, rect := range p.Rects() {
-		// The ID of the rectangle is the index of the path (assigned above).
-		path := paths[rect.ID]
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, nil, err
-		}
-
-		// Decode the image at that path
-		src, _, err := image.Decode(file)
-		file.Close()
-		if err != nil {
-			return nil, nil, err
-		}
-
-		// Draw the image onto the destination at the rectangles location.
-		bounds := image.Rect(rect.X, rect.Y, rect.Right(), rect.Bottom())
-		draw.Draw(dst, bounds, src, zero, draw.Src)
-
-		// Map the path to the rectangle where the image is drawn at.
-		mapping[path] = rect
-	}
-
-	// Return the results
-	return dst, mapping, nil
-}
+// rng is the source of random values for tests, which is seeded so that failures are
+// reproducible.
+var rng = rand.New(rand.NewSource(1))
 
 // randomSize returns a size within the given minimum and maximum sizes.
 func randomSize(id int, minSize, maxSize Size) Size {
-	w := rand.Intn(maxSize.Width-minSize.Width) + minSize.Width
-	h := rand.Intn(maxSize.Height-minSize.Height) + minSize.Height
+	w := rng.Intn(maxSize.Width-minSize.Width) + minSize.Width
+	h := rng.Intn(maxSize.Height-minSize.Height) + minSize.Height
 	return NewSizeID(id, w, h)
 }
 
@@ -90,9 +29,9 @@ func randomSize(id int, minSize, maxSize Size) Size {
 func randomColor() color.RGBA {
 	// Offset to use a minimum value so it is never pure black.
 	return color.RGBA{
-		R: uint8(rand.Intn(240)) + 15,
-		G: uint8(rand.Intn(240)) + 15,
-		B: uint8(rand.Intn(240)) + 15,
+		R: uint8(rng.Intn(240)) + 15,
+		G: uint8(rng.Intn(240)) + 15,
+		B: uint8(rng.Intn(240)) + 15,
 		A: 255,
 	}
 }
@@ -119,26 +58,6 @@ func createImage(t *testing.T, path string, packer *Packer) {
 	}
 }
 
-func TestAtlas(t *testing.T) {
-	return
-	paths, _ := filepath.Glob("/usr/share/icons/Adwaita/32x32/devices/*.png")
-	packer, _ := NewPacker(512, 512, MaxRectsBAF)
-
-	img, mapping, err := createAtlas(packer, paths)
-	if err != nil {
-		fmt.Println(err)
-		log.Fatal(err)
-	}
-
-	for k, v := range mapping {
-		fmt.Printf("%v: %s\n", k, v.String())
-	}
-
-	file, _ := os.Create("atlas.png")
-	defer file.Close()
-	png.Encode(file, img)
-}
-
 func TestRandom(t *testing.T) {
 	const count = 1024
 	minSize := NewSize(32, 32)
@@ -161,17 +80,985 @@ func TestRandom(t *testing.T) {
 	}
 
 	packer.Pack()
-	// Compare every rectangle to every other and test for intersection
-	rects := packer.Rects()
-	for i := 0; i < len(rects)-1; i++ {
-		for j := i + 1; j < len(rects); j++ {
-			if rects[i].Intersects(rects[j]) {
-				t.Errorf("%s and %s intersect\n", rects[i].String(), rects[j].String())
+	for _, violation := range packer.Validate(sizes) {
+		t.Error(violation.String())
+	}
+
+	createImage(t, "packed.png", packer)
+}
+
+func TestValidate(t *testing.T) {
+	sizes := []Size{NewSizeID(0, 10, 10), NewSizeID(1, 10, 20), NewSizeID(2, 5, 5), NewSizeID(3, 8, 8), NewSizeID(4, 4, 4)}
+	sizes[3].Options.NoFlip = true
+	rects := []Rect{
+		NewRect(0, 0, 10, 10),
+		NewRect(11, 0, 20, 10), // padding violated by 0, flipped dimensions without being flipped
+		NewRect(3, 3, 5, 5),    // overlaps 0
+		NewRect(96, 96, 8, 8),  // out of bounds
+		NewRect(40, 40, 5, 5),  // duplicate of 2
+		NewRect(60, 60, 4, 4),  // unknown
+		NewRect(70, 0, 4, 4),   // overlaps reserved
+	}
+	rects[1].ID, rects[2].ID, rects[3].ID, rects[4].ID, rects[5].ID, rects[6].ID = 1, 2, 3, 2, 5, 4
+
+	violations := Validate(Layout{
+		Bounds:   NewSize(100, 100),
+		Padding:  2,
+		Rects:    rects,
+		Reserved: []Rect{NewRect(72, 0, 10, 10)},
+		Sizes:    append(sizes, NewSizeID(6, 1, 1), NewSizeID(7, 1, 1)),
+		Aliases:  map[int]int{7: 0},
+	})
+
+	expected := []ViolationKind{ViolationBounds, ViolationOverlap, ViolationPadding, ViolationReserved, ViolationFlipped, ViolationDuplicate, ViolationUnknown, ViolationMissing}
+	var kinds []ViolationKind
+	for _, violation := range violations {
+		kinds = append(kinds, violation.Kind)
+	}
+	if !slices.Equal(kinds, expected) {
+		t.Fatalf("expected violations %v, got %v", expected, kinds)
+	}
+	if v := violations[len(violations)-1]; v.Rect.ID != 6 {
+		t.Errorf("expected size 6 to be missing, got %s", v.String())
+	}
+
+	// A large grid with a single overlap in the last row.
+	const columns, rows = 400, 250
+	rects = rects[:0]
+	for y := 0; y < rows; y++ {
+		for x := 0; x < columns; x++ {
+			rect := NewRect(x*10, y*10, 9, 9)
+			rect.ID = len(rects)
+			rects = append(rects, rect)
+		}
+	}
+	rects[len(rects)-1].X -= 5
+	violations = Validate(Layout{Bounds: NewSize(columns*10, rows*10), Padding: 1, Rects: rects})
+	if len(violations) != 1 || violations[0].Kind != ViolationOverlap {
+		t.Errorf("expected a single overlap, got %v", violations)
+	}
+}
+
+func TestNewRectLTRB(t *testing.T) {
+	if rect := NewRectLTRB(1, 2, 4, 8); rect != NewRect(1, 2, 3, 6) {
+		t.Errorf("expected <1, 2, 3, 6>, got %s", rect.String())
+	}
+}
+
+func TestPadding(t *testing.T) {
+	// Padding is only added to the right and bottom edges, so a rectangle in the corner of the bin
+	// is neither offset from the edges nor shrunk.
+	for _, heuristic := range validHeuristics() {
+		packer, _ := NewPacker(64, 64, heuristic)
+		packer.Padding = 4
+		packer.InsertSize(1, 20, 10)
+		if !packer.Pack() {
+			t.Fatalf("%s: failed to pack", heuristic.String())
+		}
+
+		rect := packer.Rects()[0]
+		if rect.X != 0 || rect.Y != 0 || rect.Width != 20 || rect.Height != 10 {
+			t.Errorf("%s: expected <0, 0, 20, 10>, got %s", heuristic.String(), rect.String())
+		}
+		if size := packer.Size(); size.Width != 24 || size.Height != 14 {
+			t.Errorf("%s: expected size of <24, 14>, got %s", heuristic.String(), size.String())
+		}
+	}
+}
+
+func TestGuillotinePadding(t *testing.T) {
+	// Each padded cell is a quarter of the bin, so no space remains after packing four of them.
+	packer, _ := NewPacker(64, 64, GuillotineBAF)
+	packer.Padding = 2
+	for i := 0; i < 4; i++ {
+		packer.InsertSize(i, 30, 30)
+	}
+	if !packer.Pack() {
+		t.Fatal("failed to pack padded rectangles into the bin")
+	}
+	if packer.InsertSize(4, 1, 1); packer.Pack() {
+		t.Error("packed a rectangle into the padding of the others")
+	}
+	for _, violation := range packer.Validate(nil) {
+		t.Error(violation.String())
+	}
+}
+
+func TestGuillotineFindPosition(t *testing.T) {
+	// The waste maps of the Skyline and Shelf algorithms use findPosition directly, so the rectangle
+	// it returns must be marked as flipped when its dimensions are swapped.
+	packer := newGuillotine(10, 30, GuillotineBAF)
+	packer.AllowFlip(true)
+
+	var index int
+	for _, height := range []int{10, 8} {
+		// A size of 30x10 fits the free rectangle perfectly when flipped, and 30x8 fits with waste.
+		node := packer.findPosition(30, height, SizeOptions{}, &index)
+		if !node.Flipped || node.Width != height || node.Height != 30 {
+			t.Errorf("expected <0, 0, %d, 30> to be flipped, got %s (flipped: %t)", height, node.String(), node.Flipped)
+		}
+	}
+	if node := packer.findPosition(30, 8, SizeOptions{NoFlip: true}, &index); node.Height != 0 {
+		t.Errorf("expected no position without flipping, got %s", node.String())
+	}
+}
+
+func TestGuillotineMerge(t *testing.T) {
+	packer := newGuillotine(20, 10, GuillotineBAF)
+	packer.freeRects = []Rect{
+		NewRect(0, 5, 10, 5),
+		NewRect(0, 0, 10, 5),
+		NewRect(10, 0, 5, 10),
+		NewRect(15, 0, 5, 10),
+	}
+	packer.mergeFreeList()
+
+	// Adjacent rectangles with a shared edge are merged, including those that were merged already.
+	expected := []Rect{NewRect(0, 0, 20, 10)}
+	if !slices.Equal(packer.freeRects, expected) {
+		t.Errorf("expected free rectangles %v, got %v", expected, packer.freeRects)
+	}
+}
+
+func TestSkylineWasteMap(t *testing.T) {
+	// A wide rectangle above two of different heights leaves a gap of 60x20 below it, which only
+	// the waste map of the MinWaste heuristic can reach.
+	for _, heuristic := range []Heuristic{SkylineBLF, SkylineMinWaste} {
+		packer, _ := NewPacker(100, 100, heuristic)
+		packer.Online = true
+		for _, size := range []Size{NewSizeID(0, 60, 10), NewSizeID(1, 40, 30), NewSizeID(2, 100, 10), NewSizeID(3, 50, 15)} {
+			packer.Insert(size)
+		}
+
+		rect := packer.Map()[3]
+		if expected := map[Heuristic]int{SkylineBLF: 40, SkylineMinWaste: 10}[heuristic]; rect.Y != expected {
+			t.Errorf("%s: expected rectangle at y = %d, got %s", heuristic.String(), expected, rect.String())
+		}
+		for _, violation := range packer.Validate(nil) {
+			t.Errorf("%s: %s", heuristic.String(), violation.String())
+		}
+	}
+}
+
+func TestShelfOpenFlipped(t *testing.T) {
+	// The size is wider than the bin, so it can only start a shelf when rotated.
+	for _, method := range []Heuristic{NextFit, FirstFit, BestAreaFit, BestWidthFit, BestHeightFit, WorstWidthFit} {
+		heuristic := Shelf | method
+		packer, _ := NewPacker(8, 20, heuristic)
+		packer.Online = true
+		packer.AllowFlip(true)
+		if !packer.InsertSize(0, 10, 5) {
+			t.Fatalf("%s: cannot pack rectangle that fits when flipped", heuristic.String())
+		}
+		if rect := packer.Rects()[0]; !rect.Flipped || rect.Width != 5 || rect.Height != 10 {
+			t.Errorf("%s: expected flipped 5x10 rectangle, got %s", heuristic.String(), rect.String())
+		}
+
+		// Rectangles that cannot be flipped still do not fit.
+		packer.Clear()
+		size := NewSizeID(1, 10, 5)
+		size.Options.NoFlip = true
+		if failed := packer.Insert(size); len(failed) != 1 || len(packer.Rects()) != 0 {
+			t.Errorf("%s: packed rectangle that cannot be flipped", heuristic.String())
+		}
+	}
+}
+
+func TestMultiPacker(t *testing.T) {
+	const count = 256
+	minSize := NewSize(32, 32)
+	maxSize := NewSize(96, 96)
+
+	for _, sel := range []BinSelect{BinFirstFit, BinBestFit, BinLast} {
+		packer, _ := NewMultiPacker(256, 256, MaxRectsBSSF)
+		packer.Select = sel
+		packer.Padding = 1
+
+		for i := 0; i < count; i++ {
+			packer.Insert(randomSize(i, minSize, maxSize))
+		}
+		// A size larger than a bin can never be packed, and must not open empty bins.
+		packer.InsertSize(count, 257, 16)
+
+		if packer.Pack() {
+			t.Fatal("oversized rectangle was packed")
+		}
+		if unpacked := packer.Unpacked(); len(unpacked) != 1 || unpacked[0].ID != count {
+			t.Fatalf("expected only the oversized rectangle to be unpacked, got %v", unpacked)
+		}
+
+		rects := packer.Rects()
+		if len(rects) != count {
+			t.Fatalf("expected %d packed rectangles, got %d", count, len(rects))
+		}
+
+		bins := packer.Bins()
+		for i, bin := range bins {
+			if len(bin.Rects()) == 0 {
+				t.Errorf("bin %d is empty", i)
+			}
+			for _, rect := range bin.Rects() {
+				if rect.Bin != i {
+					t.Errorf("%s reports bin %d, expected %d", rect.String(), rect.Bin, i)
+				}
+			}
+		}
+
+		for i := 0; i < len(rects)-1; i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Bin == rects[j].Bin && rects[i].Intersects(rects[j]) {
+					t.Errorf("%s and %s intersect\n", rects[i].String(), rects[j].String())
+				}
 			}
 		}
 	}
+}
 
-	createImage(t, "packed.png", packer)
+func TestPackAuto(t *testing.T) {
+	constraints := []SizeConstraint{
+		{MaxWidth: 2048, MaxHeight: 2048},
+		{MaxWidth: 2048, MaxHeight: 2048, PowerOfTwo: true},
+		{MaxWidth: 2048, MaxHeight: 2048, Square: true},
+		{MaxWidth: 2048, MaxHeight: 2048, Multiple: 4, Aspect: 2.0},
+	}
+
+	for _, constraint := range constraints {
+		packer, _ := NewPacker(1, 1, SkylineBLF)
+		packer.Padding = 1
+		for i := 0; i < 128; i++ {
+			packer.Insert(randomSize(i, NewSize(8, 8), NewSize(64, 64)))
+		}
+
+		size, ok := packer.PackAuto(constraint)
+		if !ok {
+			t.Fatalf("%+v: cannot fit all rectangles", constraint)
+		}
+		if len(packer.Rects()) != 128 {
+			t.Fatalf("%+v: expected 128 packed rectangles, got %d", constraint, len(packer.Rects()))
+		}
+
+		switch {
+		case constraint.PowerOfTwo && (size.Width&(size.Width-1) != 0 || size.Height&(size.Height-1) != 0):
+			t.Errorf("%+v: %s is not a power of two", constraint, size.String())
+		case constraint.Square && size.Width != size.Height:
+			t.Errorf("%+v: %s is not square", constraint, size.String())
+		case constraint.Multiple > 1 && (size.Width%constraint.Multiple != 0 || size.Height%constraint.Multiple != 0):
+			t.Errorf("%+v: %s is not a multiple of %d", constraint, size.String(), constraint.Multiple)
+		}
+
+		bounds := packer.Size()
+		if bounds.Width > size.Width || bounds.Height > size.Height {
+			t.Errorf("%+v: packed size %s exceeds chosen size %s", constraint, bounds.String(), size.String())
+		}
+	}
+
+	// Impossible constraints must report failure and keep the remaining sizes staged.
+	packer, _ := NewPacker(1, 1, MaxRectsBSSF)
+	packer.InsertSize(0, 100, 100)
+	if _, ok := packer.PackAuto(SizeConstraint{MaxWidth: 64, MaxHeight: 64}); ok {
+		t.Error("rectangle larger than the maximum size was packed")
+	}
+	if len(packer.Unpacked()) != 1 {
+		t.Errorf("expected 1 unpacked rectangle, got %d", len(packer.Unpacked()))
+	}
+
+	// The smallest size may use a width far from any other candidate, and must account for the
+	// padding of each size.
+	sizes := []struct {
+		size     Size
+		expected Size
+	}{
+		{NewSize(101, 7), NewSize(101, 7)},
+		{Size{Width: 101, Height: 7, Options: SizeOptions{Padding: 3}}, NewSize(104, 10)},
+	}
+	for _, tc := range sizes {
+		packer, _ := NewPacker(1, 1, SkylineBLF)
+		packer.Insert(tc.size)
+		size, ok := packer.PackAuto(SizeConstraint{MaxWidth: 2048, MaxHeight: 2048})
+		if !ok || size.Width != tc.expected.Width || size.Height != tc.expected.Height {
+			t.Errorf("PackAuto chose %s for %s, expected %s", size.String(), tc.size.String(), tc.expected.String())
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	sizes := make([]Size, 64)
+	for i := range sizes {
+		sizes[i] = randomSize(i, NewSize(8, 8), NewSize(48, 48))
+	}
+
+	for _, objective := range []Objective{ObjectiveBins, ObjectiveArea, ObjectiveUsed} {
+		result, err := Search(128, 128, sizes, SearchOptions{Objective: objective, AllowFlip: true, Workers: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := result.Heuristic.Validate(); err != nil {
+			t.Errorf("winning heuristic %s is invalid: %v", result.Heuristic.String(), err)
+		}
+		if len(result.Packer.Unpacked()) != 0 || len(result.Packer.Rects()) != len(sizes) {
+			t.Errorf("%s did not pack all rectangles", result.Heuristic.String())
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	sizes := make([]Size, 48)
+	for i := range sizes {
+		sizes[i] = randomSize(i+100, NewSize(8, 8), NewSize(48, 48))
+	}
+
+	opts := OptimizeOptions{Heuristic: SkylineBLF, Objective: ObjectiveArea, AllowFlip: true, Iterations: 200, Seed: 1}
+	result, err := Optimize(context.Background(), 256, 256, sizes, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != opts.Iterations {
+		t.Errorf("expected %d iterations, got %d", opts.Iterations, result.Iterations)
+	}
+
+	// The result can never be worse than the initial order, which is sorted by area.
+	initial, _ := NewMultiPacker(256, 256, opts.Heuristic)
+	initial.AllowFlip(true)
+	initial.Insert(sizes...)
+	initial.Pack()
+	if compareResults(result.Packer, initial, opts.Objective) > 0 {
+		t.Error("optimized result is worse than the initial order")
+	}
+
+	// Packed rectangles must have their original IDs and be flipped relative to the original sizes.
+	rects := result.Packer.Rects()
+	if len(rects) != len(sizes) {
+		t.Fatalf("expected %d packed rectangles, got %d", len(sizes), len(rects))
+	}
+	for _, rect := range rects {
+		size := sizes[rect.ID-100]
+		if rect.Flipped {
+			size.Width, size.Height = size.Height, size.Width
+		}
+		if rect.Width != size.Width || rect.Height != size.Height {
+			t.Errorf("rectangle %d %s does not match its size (flipped: %t)", rect.ID, rect.String(), rect.Flipped)
+		}
+	}
+
+	// The same seed must produce the same result.
+	again, _ := Optimize(context.Background(), 256, 256, sizes, opts)
+	if !slices.Equal(again.Packer.Rects(), rects) || !slices.Equal(again.Order, result.Order) {
+		t.Error("optimizing with the same seed produced a different result")
+	}
+
+	// The best result so far is returned when the context is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err = Optimize(ctx, 256, 256, sizes, opts)
+	if err != context.Canceled || result == nil || len(result.Packer.Rects()) != len(sizes) {
+		t.Errorf("expected a result and cancellation error, got %v", err)
+	}
+
+	// The options of each size are retained, so those that cannot be flipped never are.
+	fixed := make([]Size, 20)
+	for i := range fixed {
+		fixed[i] = randomSize(i, NewSize(8, 8), NewSize(48, 48))
+		fixed[i].Options.NoFlip = true
+	}
+	opts = OptimizeOptions{Heuristic: MaxRectsBSSF, AllowFlip: true, Iterations: 300, Seed: 1}
+	result, err = Optimize(context.Background(), 256, 256, fixed, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, size := range result.Order {
+		if size != fixed[size.ID] {
+			t.Errorf("size %d was changed to %v", i, size)
+		}
+	}
+	for _, rect := range result.Packer.Rects() {
+		if rect.Flipped || !rect.Options.NoFlip {
+			t.Errorf("rectangle %d %s was flipped or lost its options", rect.ID, rect.String())
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	heuristics := []Heuristic{MaxRectsBSSF, MaxRectsCP, SkylineBLF, SkylineMinWaste, GuillotineBAF}
+	for _, heuristic := range heuristics {
+		// Fill the bin completely, so that the only space available is what gets removed.
+		packer, _ := NewPacker(128, 128, heuristic)
+		packer.Padding = 2
+		for i := 0; i < 4; i++ {
+			packer.InsertSize(i, 62, 62)
+		}
+		if !packer.Pack() {
+			t.Fatalf("%s: cannot fit all rectangles", heuristic.String())
+		}
+
+		last := packer.Rects()[3]
+		used := packer.Used(false)
+		if !packer.Remove(last.ID) {
+			t.Fatalf("%s: failed to remove %d", heuristic.String(), last.ID)
+		}
+		if packer.Remove(last.ID) {
+			t.Fatalf("%s: removed %d twice", heuristic.String(), last.ID)
+		}
+		if len(packer.Rects()) != 3 || packer.Used(false) >= used {
+			t.Fatalf("%s: removal not reflected in packed rectangles", heuristic.String())
+		}
+
+		packer.Online = true
+		if !packer.InsertSize(4, 62, 62) {
+			t.Fatalf("%s: space of removed rectangle was not reclaimed", heuristic.String())
+		}
+		if packer.Used(false) != used {
+			t.Errorf("%s: expected %v used, got %v", heuristic.String(), used, packer.Used(false))
+		}
+
+		rects := packer.Rects()
+		for i := 0; i < len(rects)-1; i++ {
+			for j := i + 1; j < len(rects); j++ {
+				if rects[i].Intersects(rects[j]) {
+					t.Errorf("%s: %s and %s intersect\n", heuristic.String(), rects[i].String(), rects[j].String())
+				}
+			}
+		}
+	}
+}
+
+func TestPlace(t *testing.T) {
+	heuristics := []Heuristic{MaxRectsBSSF, MaxRectsCP, SkylineBLF, SkylineMinWaste, GuillotineBAF}
+	for _, heuristic := range heuristics {
+		packer, _ := NewPacker(128, 128, heuristic)
+		packer.Padding = 2
+
+		placed := []Rect{
+			NewRect(0, 0, 62, 62),
+			NewRect(64, 0, 62, 62),
+			NewRect(0, 64, 62, 62),
+			NewRect(10, 10, 4, 4),
+			NewRect(120, 120, 16, 16),
+		}
+		for i := range placed {
+			placed[i].ID = i
+		}
+		failed := packer.Place(placed...)
+		if len(failed) != 2 || failed[0].ID != 3 || failed[1].ID != 4 {
+			t.Fatalf("%s: expected overlapping and out of bounds rectangles to fail, got %v", heuristic.String(), failed)
+		}
+		if len(packer.Rects()) != 3 {
+			t.Fatalf("%s: expected 3 placed rectangles, got %d", heuristic.String(), len(packer.Rects()))
+		}
+
+		// The only remaining space is the bottom-right quarter.
+		packer.Online = true
+		if !packer.InsertSize(5, 62, 62) {
+			t.Fatalf("%s: cannot fit rectangle into remaining space", heuristic.String())
+		}
+		if rect := packer.Rects()[3]; rect.X != 64 || rect.Y != 64 {
+			t.Errorf("%s: expected rectangle at 64,64, got %s", heuristic.String(), rect.String())
+		}
+		if packer.InsertSize(6, 1, 1) {
+			t.Errorf("%s: packed rectangle into a full bin", heuristic.String())
+		}
+		if used := packer.Used(false); used != 1 {
+			t.Errorf("%s: expected bin to be full, got %v used", heuristic.String(), used)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	heuristics := []Heuristic{MaxRectsBSSF, MaxRectsCP, SkylineBLF, SkylineMinWaste, GuillotineBAF}
+	for _, heuristic := range heuristics {
+		packer, _ := NewPacker(128, 128, heuristic)
+		packer.Padding = 2
+
+		reserved := NewRect(0, 0, 64, 64)
+		failed := packer.Reserve(reserved, NewRect(32, 32, 8, 8), NewRect(120, 0, 16, 16))
+		if len(failed) != 2 || len(packer.Reserved()) != 1 {
+			t.Fatalf("%s: expected overlapping and out of bounds reservations to fail, got %v", heuristic.String(), failed)
+		}
+		if len(packer.Rects()) != 0 || packer.Used(false) != 0.25 {
+			t.Fatalf("%s: reservation not reflected in used area", heuristic.String())
+		}
+
+		for pass := 0; pass < 2; pass++ {
+			for i := 0; i < 3; i++ {
+				packer.InsertSize(i, 62, 62)
+			}
+			if !packer.Pack() {
+				t.Fatalf("%s: cannot fit rectangles around reservation", heuristic.String())
+			}
+			for _, rect := range packer.Rects() {
+				if rect.Intersects(reserved) {
+					t.Errorf("%s: %s intersects reserved area", heuristic.String(), rect.String())
+				}
+			}
+			if packer.Used(false) != 1 {
+				t.Errorf("%s: expected bin to be full, got %v used", heuristic.String(), packer.Used(false))
+			}
+
+			// Reservations are retained when the packer is cleared.
+			packer.Clear()
+			if len(packer.Reserved()) != 1 || packer.Used(false) != 0.25 {
+				t.Fatalf("%s: reservation not retained after clearing", heuristic.String())
+			}
+		}
+	}
+}
+
+func TestBlockAlign(t *testing.T) {
+	const block = 4
+	heuristics := []Heuristic{MaxRectsBSSF, MaxRectsCP, SkylineBLF, SkylineMinWaste, GuillotineBAF, ShelfBestAreaFit | WasteMap, BinaryTree, BinaryTreeGrow}
+	for _, heuristic := range heuristics {
+		packer, _ := NewPacker(256, 256, heuristic)
+		packer.Padding = 1
+		packer.AllowFlip(true)
+		if !packer.BlockAlign(block) {
+			t.Fatalf("%s: block alignment not supported", heuristic.String())
+		}
+		packer.Reserve(NewRect(0, 0, 5, 5))
+
+		sizes := make([]Size, 100)
+		for i := range sizes {
+			sizes[i] = randomSize(i, NewSize(1, 1), NewSize(24, 24))
+		}
+		packer.Insert(sizes...)
+		packer.Pack()
+
+		original := make(map[int]Size)
+		for _, size := range sizes {
+			original[size.ID] = size
+		}
+
+		// Each rectangle must start on a block boundary, and no block may contain more than one.
+		blocks := make(map[Point]int)
+		for _, rect := range packer.Rects() {
+			size := original[rect.ID]
+			if rect.Flipped {
+				size.Width, size.Height = size.Height, size.Width
+			}
+			if rect.Width != size.Width || rect.Height != size.Height {
+				t.Errorf("%s: %s does not have its original size", heuristic.String(), rect.String())
+			}
+			if rect.X%block != 0 || rect.Y%block != 0 {
+				t.Errorf("%s: %s is not aligned to blocks", heuristic.String(), rect.String())
+			}
+			for y := rect.Y / block; y <= (rect.Bottom()-1)/block; y++ {
+				for x := rect.X / block; x <= (rect.Right()-1)/block; x++ {
+					if x < 2 && y < 2 {
+						t.Errorf("%s: %s shares a block with a reserved area", heuristic.String(), rect.String())
+					}
+					if id, ok := blocks[NewPoint(x, y)]; ok {
+						t.Errorf("%s: %d and %d share a block", heuristic.String(), id, rect.ID)
+					}
+					blocks[NewPoint(x, y)] = rect.ID
+				}
+			}
+		}
+
+		if size := packer.Size(); size.Width%block != 0 || size.Height%block != 0 {
+			t.Errorf("%s: size %vx%v is not a multiple of the block size", heuristic.String(), size.Width, size.Height)
+		}
+
+		data, err := packer.MarshalJSON()
+		if err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		var restored Packer
+		if err := restored.UnmarshalJSON(data); err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		if restored.blockSize() != block {
+			t.Errorf("%s: block size was not restored", heuristic.String())
+		}
+	}
+
+	packer := NewDefaultPacker()
+	packer.BlockAlign(block)
+	packer.Insert(NewSize(30, 30), NewSize(7, 9))
+	if size, ok := packer.PackAuto(SizeConstraint{}); !ok || size.Width%block != 0 || size.Height%block != 0 {
+		t.Errorf("PackAuto chose %vx%v, which is not a multiple of the block size", size.Width, size.Height)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	packer, _ := NewPacker(64, 64, MaxRectsBSSF)
+	packer.InsertSize(0, 8, 8)
+	packer.InsertSize(1, 16, 16)
+	packer.Pack()
+
+	packer.Alias(2, 0)
+	packer.Alias(3, 2)
+	packer.Alias(4, 5)
+
+	mapping := packer.Map()
+	if len(mapping) != 4 || mapping[2] != mapping[0] || mapping[3] != mapping[0] {
+		t.Errorf("expected aliases to map to the rectangle of their target, got %v", mapping)
+	}
+	if packer.Aliases()[3] != 0 {
+		t.Errorf("expected alias of an alias to refer to the same rectangle, got %d", packer.Aliases()[3])
+	}
+
+	// Making a target an alias redirects everything that referred to it.
+	packer.Alias(0, 1)
+	if aliases := packer.Aliases(); aliases[2] != 1 || aliases[3] != 1 {
+		t.Errorf("expected aliases to be redirected, got %v", aliases)
+	}
+
+	data, err := packer.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Packer
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(restored.Aliases(), packer.Aliases()) {
+		t.Errorf("expected aliases %v to be restored, got %v", packer.Aliases(), restored.Aliases())
+	}
+
+	packer.Clear()
+	if len(packer.Aliases()) != 0 {
+		t.Error("expected aliases to be removed by Clear")
+	}
+}
+
+func TestPolygonPacker(t *testing.T) {
+	// Two right triangles with 32 pixel sides interlock when one is rotated, sharing only the
+	// pixels along the diagonal.
+	triangle := []Point{NewPoint(0, 0), NewPoint(32, 0), NewPoint(0, 32)}
+	packer, _ := NewPolygonPacker(33, 32)
+	packer.Rotations = 4
+	packer.Insert(NewPolygon(0, triangle...), NewPolygon(1, triangle...))
+	if !packer.Pack() {
+		t.Fatal("triangles did not interlock")
+	}
+
+	// Octagons and triangles packed with padding must never share a pixel.
+	packer, _ = NewPolygonPacker(256, 256)
+	packer.Padding = 1
+	packer.Rotations = 4
+	for i := 0; i < 40; i++ {
+		r := 4 + i%12
+		octagon := NewPolygon(i, NewPoint(r, 0), NewPoint(2*r, 0), NewPoint(3*r, r), NewPoint(3*r, 2*r),
+			NewPoint(2*r, 3*r), NewPoint(r, 3*r), NewPoint(0, 2*r), NewPoint(0, r))
+		packer.Insert(octagon, NewPolygon(100+i, NewPoint(0, 0), NewPoint(2*r, r), NewPoint(0, 3*r)))
+	}
+	packer.Pack()
+	if len(packer.Rects()) == 0 {
+		t.Fatal("no polygons were packed")
+	}
+
+	occupied := make(map[Point]int)
+	for _, rect := range packer.Rects() {
+		if rect.X < 0 || rect.Y < 0 || rect.Right() > 256 || rect.Bottom() > 256 {
+			t.Errorf("%s is out of bounds", rect.String())
+		}
+		if len(rect.Triangles) != len(rect.Vertices)-2 {
+			t.Errorf("%s: expected %d triangles, got %d", rect.String(), len(rect.Vertices)-2, len(rect.Triangles))
+		}
+		for i, v := range rect.Vertices {
+			uv := rect.UVs[i]
+			if uv != rect.Transform(v) || uv.X < rect.X || uv.Y < rect.Y || uv.X > rect.Right() || uv.Y > rect.Bottom() {
+				t.Errorf("%s: vertex %v has invalid UV %v", rect.String(), v, uv)
+			}
+		}
+
+		mask := packer.rasterize(rect.UVs, 0)
+		for row, s := range mask.spans {
+			for x := s.left; x < s.right; x++ {
+				point := NewPoint(mask.bounds.X+x, mask.bounds.Y+row)
+				if other, ok := occupied[point]; ok {
+					t.Fatalf("%d and %d overlap at %v", other, rect.ID, point)
+				}
+				occupied[point] = rect.ID
+			}
+		}
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	points := []Point{
+		NewPoint(0, 0), NewPoint(4, 0), NewPoint(2, 0), NewPoint(4, 4),
+		NewPoint(0, 4), NewPoint(2, 2), NewPoint(1, 3), NewPoint(0, 0),
+	}
+	expected := []Point{NewPoint(0, 0), NewPoint(4, 0), NewPoint(4, 4), NewPoint(0, 4)}
+	if hull := ConvexHull(points); !slices.Equal(hull, expected) {
+		t.Errorf("expected %v, got %v", expected, hull)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	heuristics := []Heuristic{MaxRectsBSSF, MaxRectsBL, SkylineMinWaste, GuillotineBAF | SplitMinimizeArea, ShelfBestAreaFit | WasteMap, BinaryTree}
+	for _, heuristic := range heuristics {
+		packer, _ := NewPacker(128, 128, heuristic)
+		packer.Padding = 1
+		packer.AllowFlip(true)
+
+		var trace bytes.Buffer
+		recorder := NewRecorder(&trace)
+		if !packer.Observe(recorder) {
+			t.Fatalf("%s: observer not supported", heuristic.String())
+		}
+		for i := 0; i < 40; i++ {
+			packer.Insert(randomSize(i, NewSize(4, 4), NewSize(24, 24)))
+		}
+		packer.Pack()
+		if err := recorder.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		var placed []Rect
+		counts := make(map[EventKind]int)
+		err := Replay(&trace, ObserverFunc(func(event Event) {
+			counts[event.Kind]++
+			if event.Kind == EventPlace {
+				placed = append(placed, event.Rect)
+			}
+		}))
+		if err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		if !slices.Equal(placed, packer.Rects()) {
+			t.Errorf("%s: placements in trace do not match packed rectangles", heuristic.String())
+		}
+		if counts[EventScore] < len(placed) || counts[EventSplit]+counts[EventMerge] == 0 {
+			t.Errorf("%s: expected score events and changes to free space, got %v", heuristic.String(), counts)
+		}
+
+		// Removing the observer stops the events.
+		packer.Observe(nil)
+		length := trace.Len()
+		packer.Insert(NewSizeID(100, 2, 2))
+		packer.Pack()
+		if trace.Len() != length {
+			t.Errorf("%s: events recorded after observer was removed", heuristic.String())
+		}
+	}
+}
+
+func TestPackContext(t *testing.T) {
+	sizes := make([]Size, 40)
+	for i := range sizes {
+		sizes[i] = randomSize(i, NewSize(4, 4), NewSize(24, 24))
+	}
+
+	for _, heuristic := range validHeuristics() {
+		for _, online := range []bool{false, true} {
+			packer, _ := NewPacker(128, 128, heuristic)
+			packer.Padding = 1
+			packer.Online = online
+
+			// Cancel after a few placements, which must stop packing before the next one.
+			ctx, cancel := context.WithCancel(context.Background())
+			packer.Observe(ObserverFunc(func(event Event) {
+				if event.Kind == EventPlace && len(packer.Rects()) == 5 {
+					cancel()
+				}
+			}))
+
+			var unpacked []Size
+			var err error
+			if online {
+				unpacked, err = packer.InsertContext(ctx, slices.Clone(sizes)...)
+			} else {
+				packer.Insert(slices.Clone(sizes)...)
+				unpacked, err = packer.PackContext(ctx)
+			}
+			cancel()
+
+			if err != context.Canceled {
+				t.Errorf("%s: expected cancellation error, got %v", heuristic.String(), err)
+			}
+			if len(packer.Rects()) != 5 || len(unpacked) != len(sizes)-5 {
+				t.Errorf("%s: expected 5 packed and %d unpacked, got %d and %d", heuristic.String(), len(sizes)-5, len(packer.Rects()), len(unpacked))
+			}
+			failed := make(map[int]bool)
+			for _, size := range unpacked {
+				failed[size.ID] = true
+			}
+			expected := slices.DeleteFunc(slices.Clone(sizes), func(size Size) bool { return failed[size.ID] })
+			for _, violation := range packer.Validate(expected) {
+				t.Errorf("%s: %s", heuristic.String(), violation.String())
+			}
+
+			// Packing resumes where it stopped.
+			packer.Observe(nil)
+			if online {
+				unpacked = packer.Insert(unpacked...)
+			} else {
+				packer.Pack()
+				unpacked = packer.Unpacked()
+			}
+			if len(packer.Rects())+len(unpacked) != len(sizes) {
+				t.Errorf("%s: %d packed and %d unpacked after resuming", heuristic.String(), len(packer.Rects()), len(unpacked))
+			}
+		}
+	}
+
+	// A MultiPacker does not open bins once the context is done, and keeps the sizes staged.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	packer, _ := NewMultiPacker(64, 64, MaxRectsBSSF)
+	packer.Insert(sizes...)
+	if unpacked, err := packer.PackContext(ctx); err != context.Canceled || len(unpacked) != len(sizes) || len(packer.Bins()) != 0 {
+		t.Errorf("expected no bins and cancellation error, got %d bins and %v", len(packer.Bins()), err)
+	}
+	if unpacked, err := packer.PackContext(context.Background()); err != nil || len(unpacked) != 0 {
+		t.Errorf("expected all sizes to be packed, got %d unpacked and %v", len(unpacked), err)
+	}
+	for _, violation := range packer.Validate(sizes) {
+		t.Error(violation.String())
+	}
+
+	packer.Online = true
+	bins := len(packer.Bins())
+	if unpacked, err := packer.InsertContext(ctx, NewSizeID(100, 64, 64)); err != context.Canceled || len(unpacked) != 1 || len(packer.Bins()) != bins {
+		t.Errorf("expected size to be unpacked with cancellation error, got %v", err)
+	}
+
+	// A context that is done only after every size was tried did not stop packing, even though
+	// some sizes did not fit. The Shelf and BinaryTree algorithms try each size in order, so the
+	// last placement is also the last attempt.
+	for _, heuristic := range []Heuristic{ShelfNextFit, BinaryTree} {
+		packer, _ := NewPacker(128, 128, heuristic)
+		packer.Online = true
+
+		ctx, cancel := context.WithCancel(context.Background())
+		packer.Observe(ObserverFunc(func(event Event) {
+			if event.Kind == EventPlace {
+				cancel()
+			}
+		}))
+		unpacked, err := packer.InsertContext(ctx, NewSizeID(0, 256, 256), NewSizeID(1, 16, 16))
+		cancel()
+		if err != nil || len(unpacked) != 1 || unpacked[0].ID != 0 {
+			t.Errorf("%s: expected only the oversized rectangle to be unpacked without an error, got %v and %v", heuristic.String(), unpacked, err)
+		}
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	heuristics := []Heuristic{MaxRectsBSSF, SkylineBLF, SkylineMinWaste, GuillotineBAF, ShelfBestAreaFit | WasteMap, BinaryTree, BinaryTreeGrow}
+	for _, heuristic := range heuristics {
+		packer, _ := NewPacker(512, 512, heuristic)
+		packer.Padding = 1
+		packer.AllowFlip(true)
+		packer.Reserve(NewRect(0, 0, 2, 2))
+
+		sizes := make([]Size, 200)
+		for i := range sizes {
+			sizes[i] = randomSize(i, NewSize(8, 8), NewSize(48, 48))
+			if i%5 == 0 {
+				sizes[i].Options = SizeOptions{NoFlip: true, Padding: 3, AlignX: 4, AlignY: 2}
+			}
+		}
+		packer.Insert(sizes[:100]...)
+		packer.Pack()
+		packer.Online = true
+
+		jsonData, err := packer.MarshalJSON()
+		if err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		binaryData, err := packer.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+
+		var fromJSON, fromBinary Packer
+		if err := fromJSON.UnmarshalJSON(jsonData); err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		if err := fromBinary.UnmarshalBinary(binaryData); err != nil {
+			t.Fatalf("%s: %v", heuristic.String(), err)
+		}
+		if err := fromBinary.UnmarshalBinary(binaryData[:len(binaryData)-1]); err == nil {
+			t.Errorf("%s: expected error for truncated data", heuristic.String())
+		}
+
+		// Future placements must be identical to those of the original.
+		for _, size := range sizes[100:] {
+			packer.Insert(size)
+			fromJSON.Insert(size)
+			fromBinary.Insert(size)
+		}
+		expected := packer.Rects()
+		if !slices.Equal(fromJSON.Rects(), expected) || !slices.Equal(fromBinary.Rects(), expected) {
+			t.Errorf("%s: restored packer does not produce identical placements", heuristic.String())
+		}
+		if fromJSON.Used(false) != packer.Used(false) || fromBinary.Used(false) != packer.Used(false) {
+			t.Errorf("%s: restored packer does not have the same used area", heuristic.String())
+		}
+	}
+
+	// The internal state of the algorithm must be consistent, or later placements may panic.
+	malformed := []string{
+		`{"heuristic":"Skyline-BL","width":100,"height":100,"sort":"area","packed":[],"skyline":[{"x":0,"y":0,"width":10}]}`,
+		`{"heuristic":"Skyline-BL","width":100,"height":100,"sort":"area","packed":[],"skyline":[{"x":0,"y":0,"width":50},{"x":60,"y":0,"width":40}]}`,
+		`{"heuristic":"Skyline-BL","width":100,"height":100,"sort":"area","packed":[],"skyline":[{"x":50,"y":0,"width":50},{"x":0,"y":0,"width":50}]}`,
+		`{"heuristic":"Shelf-BAF","width":100,"height":100,"sort":"area","packed":[],"shelves":[{"y":0,"height":20,"x":0},{"y":10,"height":20,"x":0}]}`,
+		`{"heuristic":"BinaryTree","width":100,"height":100,"sort":"area","packed":[],"tree":[{"x":0,"y":0,"width":50,"height":50}]}`,
+		`{"heuristic":"BinaryTree","width":100,"height":100,"sort":"area","packed":[],"tree":[{"x":0,"y":0,"width":100,"height":100,"children":2},{"x":0,"y":0,"width":60,"height":100},{"x":50,"y":0,"width":50,"height":100}]}`,
+		`{"heuristic":"BinaryTree","width":100,"height":100,"sort":"area","packed":[],"tree":[{"x":0,"y":0,"width":100,"height":100,"children":1},{"x":0,"y":0,"width":50,"height":100}]}`,
+	}
+	for _, data := range malformed {
+		var packer Packer
+		if err := packer.UnmarshalJSON([]byte(data)); err == nil {
+			t.Errorf("expected error for malformed snapshot %s", data)
+		}
+	}
+
+	// The used area is computed from the rectangles rather than trusted.
+	var restored Packer
+	data := `{"heuristic":"Skyline-BL","width":100,"height":100,"padding":1,"sort":"area","usedArea":5000,` +
+		`"packed":[{"x":0,"y":0,"width":9,"height":9}],"skyline":[{"x":0,"y":10,"width":10},{"x":10,"y":0,"width":90}]}`
+	if err := restored.UnmarshalJSON([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if used := restored.Used(false); used != 0.01 {
+		t.Errorf("expected used ratio of 0.01, got %v", used)
+	}
+
+	packer := NewDefaultPacker()
+	packer.Sorter(func(a, b Size) int { return a.Width - b.Width }, false)
+	if _, err := packer.MarshalJSON(); err == nil {
+		t.Error("expected error for custom sort function")
+	}
+}
+
+func TestParseHeuristic(t *testing.T) {
+	for _, heuristic := range validHeuristics() {
+		parsed, err := ParseHeuristic(heuristic.String())
+		if err != nil || parsed != heuristic {
+			t.Errorf("%s: parsed as %s (%v)", heuristic.String(), parsed.String(), err)
+		}
+	}
+
+	if parsed, _ := ParseHeuristic("guillotine-baf"); parsed != Heuristic(GuillotineBAF) {
+		t.Errorf("expected %s, got %s", Heuristic(GuillotineBAF).String(), parsed.String())
+	}
+	if _, err := ParseHeuristic("Skyline-BSSF"); err == nil {
+		t.Error("invalid heuristic was parsed")
+	}
+}
+
+func TestHeuristicValidate(t *testing.T) {
+	// The Guillotine algorithm scores by the leftover space of a free rectangle, so it supports the
+	// worst fits, but has no notion of a bottom-left or contact point position.
+	for _, bin := range []Heuristic{BestShortSideFit, BestLongSideFit, BestAreaFit, WorstShortSideFit, WorstLongSideFit, WorstAreaFit} {
+		if heuristic := Guillotine | bin | SplitMinimizeArea; heuristic.Validate() != nil {
+			t.Errorf("%s: expected to be valid, got %v", heuristic.String(), heuristic.Validate())
+		}
+	}
+	for _, bin := range []Heuristic{BottomLeft, ContactPoint} {
+		if heuristic := Guillotine | bin | SplitMinimizeArea; heuristic.Validate() != binErr {
+			t.Errorf("%s: expected %v, got %v", heuristic.String(), binErr, heuristic.Validate())
+		}
+	}
+
+	presets := []Heuristic{GuillotineBSSF, GuillotineBLSF, GuillotineBAF, GuillotineWSSF, GuillotineWLSF, GuillotineWAF}
+	for _, heuristic := range presets {
+		if err := heuristic.Validate(); err != nil {
+			t.Errorf("%s: preset is invalid: %v", heuristic.String(), err)
+		}
+	}
 }
 
 // vim: ts=4
@@ -0,0 +1,504 @@
+package rectpack
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// Polygon is a shape that is packed by a PolygonPacker, described by the outline of the visible
+// area of a sprite.
+type Polygon struct {
+	// ID is a user-defined identifier that can be used to differentiate this instance from
+	// others.
+	ID int
+	// Vertices contains the outline of the polygon in order, in either winding direction. The
+	// vertices are in the coordinates of the sprite and lie on the corners of its pixels, such
+	// that an entire sprite of size WxH is described by <0, 0>, <W, 0>, <W, H>, and <0, H>.
+	Vertices []Point
+}
+
+// NewPolygon creates a new polygon with the specified ID and vertices.
+func NewPolygon(id int, vertices ...Point) Polygon {
+	return Polygon{ID: id, Vertices: vertices}
+}
+
+// Bounds returns the smallest rectangle that contains all vertices of the polygon.
+func (p *Polygon) Bounds() Rect {
+	if len(p.Vertices) == 0 {
+		return Rect{}
+	}
+
+	left, top := p.Vertices[0].X, p.Vertices[0].Y
+	right, bottom := left, top
+	for _, v := range p.Vertices[1:] {
+		left, top = min(left, v.X), min(top, v.Y)
+		right, bottom = max(right, v.X), max(bottom, v.Y)
+	}
+	return NewRect(left, top, right-left, bottom-top)
+}
+
+// Area computes the area enclosed by the polygon, which is only meaningful when its edges do not
+// intersect each other.
+func (p *Polygon) Area() float64 {
+	return math.Abs(float64(signedArea(p.Vertices))) / 2
+}
+
+// String implements the Stringer interface.
+func (p *Polygon) String() string {
+	return fmt.Sprintf("Polygon(%d, %v)", p.ID, p.Vertices)
+}
+
+// PolygonRect describes a polygon that has been packed, in the style of the polygon mode of
+// TexturePacker.
+type PolygonRect struct {
+	// Rect is the bounds of the polygon within the bin. The ID is that of the polygon, and it is
+	// flipped when the polygon was rotated an odd number of times.
+	Rect
+	// Rotation is the number of times the polygon was rotated 90 degrees clockwise, from 0 to 3.
+	Rotation int
+	// Offset is the translation that is applied after rotating, which is the location of the
+	// origin of the sprite within the bin.
+	Offset Point
+	// Vertices contains the outline of the polygon in the coordinates of the sprite, as it was
+	// given.
+	Vertices []Point
+	// UVs contains the location of each vertex within the bin.
+	UVs []Point
+	// Triangles contains the indices of the vertices of each triangle that the polygon is divided
+	// into for rendering.
+	Triangles [][3]int
+}
+
+// Transform converts a point in the coordinates of the sprite to its location within the bin.
+func (r *PolygonRect) Transform(point Point) Point {
+	point = rotatePoint(point, r.Rotation)
+	return NewPoint(point.X+r.Offset.X, point.Y+r.Offset.Y)
+}
+
+// span is the range of columns that a polygon occupies within a single row, where the right
+// edge is exclusive. The span is empty when right is not greater than left.
+type span struct {
+	left, right int
+}
+
+// polygonMask is the rasterized form of a polygon in a single orientation.
+type polygonMask struct {
+	// bounds is the bounds of the rotated polygon, before it is translated into the bin.
+	bounds Rect
+	// spans contains the columns occupied by each row of the bounds, relative to its left edge.
+	spans []span
+	// padded contains the columns occupied by each row including the padding, which extends
+	// below the bounds.
+	padded []span
+	// area is the number of pixels occupied by the polygon without padding.
+	area int
+}
+
+// PolygonPacker is an experimental packer that places sprites by the outline of their visible
+// area instead of their bounds, allowing round and diagonal shapes to interlock. The free space
+// is tracked per pixel, so packing is considerably slower than with the rectangle algorithms, and
+// is best suited to smaller bins.
+//
+// Each row of a polygon is treated as occupying the range between its leftmost and rightmost
+// extents, which is exact for convex polygons such as those created by ConvexHull.
+type PolygonPacker struct {
+	// maxWidth is the width of the bin.
+	maxWidth int
+	// maxHeight is the height of the bin.
+	maxHeight int
+	// rows contains a set of bits for each row of the bin, indicating which pixels are occupied.
+	rows [][]uint64
+	// packed contains the polygons that have been packed.
+	packed []PolygonRect
+	// unpacked contains polygons that have not yet been packed or unable to be packed.
+	unpacked []Polygon
+	// usedArea is the number of pixels occupied by packed polygons, excluding padding.
+	usedArea int
+	// Padding defines the amount of empty space to place around polygons, which is applied to
+	// the right and bottom of each row in the same manner as rectangles. Values of 0 or less
+	// indicates that polygons will be tightly packed.
+	//
+	// Default: 0
+	Padding int
+	// Rotations is the number of orientations tested for each polygon, where each is rotated a
+	// further 90 degrees clockwise. Values of 1 or less only test the original orientation, and
+	// values greater than 4 are the same as 4.
+	//
+	// Default: 1
+	Rotations int
+}
+
+// NewPolygonPacker initializes a new PolygonPacker with the specified bin size.
+func NewPolygonPacker(maxWidth, maxHeight int) (*PolygonPacker, error) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		return nil, fmt.Errorf("width and height must be greater than 0 (given %vx%v)", maxWidth, maxHeight)
+	}
+
+	p := &PolygonPacker{maxWidth: maxWidth, maxHeight: maxHeight, Rotations: 1}
+	p.Clear()
+	return p, nil
+}
+
+// Insert stages polygons to be packed with the next call to Pack. The return value contains all
+// polygons that are currently staged.
+func (p *PolygonPacker) Insert(polygons ...Polygon) []Polygon {
+	p.unpacked = append(p.unpacked, polygons...)
+	return p.unpacked
+}
+
+// Pack packs all staged polygons, beginning with those of the largest area. Each polygon is
+// placed in whichever orientation results in the lowest bottom edge, and then the leftmost.
+//
+// The return value indicates if all staged polygons were successfully packed. When false,
+// Unpacked can be used to retrieve the polygons that failed. Polygons with less than 3 vertices
+// or without any area can never be packed.
+func (p *PolygonPacker) Pack() bool {
+	slices.SortStableFunc(p.unpacked, func(a, b Polygon) int {
+		return cmp.Compare(b.Area(), a.Area())
+	})
+
+	failed := p.unpacked[:0]
+	for _, polygon := range p.unpacked {
+		if !p.insert(polygon) {
+			failed = append(failed, polygon)
+		}
+	}
+	p.unpacked = failed
+	return len(p.unpacked) == 0
+}
+
+// Rects returns a slice of the polygons that are currently packed.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *PolygonPacker) Rects() []PolygonRect {
+	return p.packed
+}
+
+// Unpacked returns a slice of polygons that are currently staged to be packed.
+//
+// The backing memory is owned by the packer, and a copy should be made if modification or
+// persistence is required.
+func (p *PolygonPacker) Unpacked() []Polygon {
+	return p.unpacked
+}
+
+// Size computes the minimum size required to contain all packed polygons.
+func (p *PolygonPacker) Size() Size {
+	var size Size
+	for _, rect := range p.packed {
+		size.Width = max(size.Width, rect.Right())
+		size.Height = max(size.Height, rect.Bottom())
+	}
+	return size
+}
+
+// Used computes the ratio of pixels occupied by polygons to the area of the bin, in the range of
+// 0.0 and 1.0.
+func (p *PolygonPacker) Used() float64 {
+	return float64(p.usedArea) / float64(p.maxWidth*p.maxHeight)
+}
+
+// Clear removes all packed and staged polygons without changing the configuration.
+func (p *PolygonPacker) Clear() {
+	words := (p.maxWidth + 63) / 64
+	p.rows = make([][]uint64, p.maxHeight)
+	for y := range p.rows {
+		p.rows[y] = make([]uint64, words)
+	}
+	p.packed = p.packed[:0]
+	p.unpacked = p.unpacked[:0]
+	p.usedArea = 0
+}
+
+func (p *PolygonPacker) insert(polygon Polygon) bool {
+	if len(polygon.Vertices) < 3 || polygon.Area() == 0 {
+		return false
+	}
+
+	var best *polygonMask
+	var bestPos Point
+	bestRotation, bestBottom := 0, math.MaxInt
+	for rotation := 0; rotation < min(max(p.Rotations, 1), 4); rotation++ {
+		mask := p.rasterize(polygon.Vertices, rotation)
+		pos, ok := p.findPosition(mask)
+		if !ok {
+			continue
+		}
+		if bottom := pos.Y + mask.bounds.Height; bottom < bestBottom || (bottom == bestBottom && pos.X < bestPos.X) {
+			best, bestPos, bestRotation, bestBottom = mask, pos, rotation, bottom
+		}
+	}
+	if best == nil {
+		return false
+	}
+
+	p.occupy(best, bestPos)
+	p.usedArea += best.area
+
+	rect := PolygonRect{
+		Rect:      NewRect(bestPos.X, bestPos.Y, best.bounds.Width, best.bounds.Height),
+		Rotation:  bestRotation,
+		Offset:    NewPoint(bestPos.X-best.bounds.X, bestPos.Y-best.bounds.Y),
+		Vertices:  slices.Clone(polygon.Vertices),
+		UVs:       make([]Point, len(polygon.Vertices)),
+		Triangles: triangulate(polygon.Vertices),
+	}
+	rect.ID = polygon.ID
+	rect.Flipped = bestRotation%2 != 0
+	for i, v := range polygon.Vertices {
+		rect.UVs[i] = rect.Transform(v)
+	}
+	p.packed = append(p.packed, rect)
+	return true
+}
+
+// rasterize computes the pixels occupied by the vertices after rotating them.
+func (p *PolygonPacker) rasterize(vertices []Point, rotation int) *polygonMask {
+	rotated := make([]Point, len(vertices))
+	for i, v := range vertices {
+		rotated[i] = rotatePoint(v, rotation)
+	}
+	polygon := Polygon{Vertices: rotated}
+	mask := &polygonMask{bounds: polygon.Bounds()}
+	mask.spans = make([]span, mask.bounds.Height)
+
+	for row := range mask.spans {
+		// Find the horizontal extents of the edges within the strip covered by the row.
+		top, bottom := float64(mask.bounds.Y+row), float64(mask.bounds.Y+row+1)
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i, a := range rotated {
+			b := rotated[(i+1)%len(rotated)]
+			if min(a.Y, b.Y) >= int(bottom) || max(a.Y, b.Y) <= int(top) {
+				continue
+			}
+			for _, y := range []float64{max(top, float64(min(a.Y, b.Y))), min(bottom, float64(max(a.Y, b.Y)))} {
+				x := float64(a.X) + (y-float64(a.Y))*float64(b.X-a.X)/float64(b.Y-a.Y)
+				lo, hi = math.Min(lo, x), math.Max(hi, x)
+			}
+		}
+		if lo < hi {
+			mask.spans[row] = span{int(math.Floor(lo)) - mask.bounds.X, int(math.Ceil(hi)) - mask.bounds.X}
+			mask.area += mask.spans[row].right - mask.spans[row].left
+		}
+	}
+
+	// The padding of each row is the union of the rows above it within the padding, extended to
+	// the right by the padding.
+	padding := max(p.Padding, 0)
+	mask.padded = make([]span, len(mask.spans)+padding)
+	for row := range mask.padded {
+		padded := span{math.MaxInt, math.MinInt}
+		for i := max(0, row-padding); i <= min(row, len(mask.spans)-1); i++ {
+			if s := mask.spans[i]; s.right > s.left {
+				padded.left, padded.right = min(padded.left, s.left), max(padded.right, s.right+padding)
+			}
+		}
+		if padded.right > padded.left {
+			mask.padded[row] = padded
+		}
+	}
+	return mask
+}
+
+// findPosition returns the top-most and then left-most location within the bin where the mask
+// can be placed without overlapping any occupied pixels.
+func (p *PolygonPacker) findPosition(mask *polygonMask) (Point, bool) {
+	for y := 0; y+mask.bounds.Height <= p.maxHeight; y++ {
+		for x := 0; x+mask.bounds.Width <= p.maxWidth; {
+			next, ok := p.collide(mask, x, y)
+			if !ok {
+				return NewPoint(x, y), true
+			}
+			x = next
+		}
+	}
+	return Point{}, false
+}
+
+// collide tests whether the mask overlaps any occupied pixels at the specified location. When it
+// does, the smallest column that could avoid the overlap is returned.
+func (p *PolygonPacker) collide(mask *polygonMask, x, y int) (int, bool) {
+	for row, s := range mask.padded {
+		if y+row >= p.maxHeight {
+			break
+		}
+		left, right := x+s.left, min(x+s.right, p.maxWidth)
+		if right <= left {
+			continue
+		}
+		if last := lastBit(p.rows[y+row], left, right); last >= 0 {
+			return last - s.left + 1, true
+		}
+	}
+	return 0, false
+}
+
+// occupy marks the pixels of the mask as occupied at the specified location.
+func (p *PolygonPacker) occupy(mask *polygonMask, pos Point) {
+	for row, s := range mask.padded {
+		if pos.Y+row >= p.maxHeight {
+			break
+		}
+		bitset := p.rows[pos.Y+row]
+		for x := pos.X + s.left; x < min(pos.X+s.right, p.maxWidth); x++ {
+			bitset[x/64] |= 1 << (x % 64)
+		}
+	}
+}
+
+// lastBit returns the index of the last set bit within the range of the bitset, or -1 if there
+// is none.
+func lastBit(bitset []uint64, start, end int) int {
+	for word := (end - 1) / 64; word >= start/64; word-- {
+		value := bitset[word]
+		if hi := end - word*64; hi < 64 {
+			value &= 1<<hi - 1
+		}
+		if lo := start - word*64; lo > 0 {
+			value &^= 1<<lo - 1
+		}
+		if value != 0 {
+			return word*64 + 63 - bits.LeadingZeros64(value)
+		}
+	}
+	return -1
+}
+
+// rotatePoint rotates a point about the origin 90 degrees clockwise the specified number of times.
+func rotatePoint(point Point, rotation int) Point {
+	switch rotation % 4 {
+	case 1:
+		return NewPoint(-point.Y, point.X)
+	case 2:
+		return NewPoint(-point.X, -point.Y)
+	case 3:
+		return NewPoint(point.Y, -point.X)
+	}
+	return point
+}
+
+// signedArea returns twice the signed area enclosed by the vertices, which is positive when they
+// are in clockwise order with the y-axis pointing down.
+func signedArea(vertices []Point) int {
+	var area int
+	for i, a := range vertices {
+		b := vertices[(i+1)%len(vertices)]
+		area += a.X*b.Y - b.X*a.Y
+	}
+	return area
+}
+
+// cross returns the cross product of the vectors from o to a and from o to b.
+func cross(o, a, b Point) int {
+	return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+}
+
+// ConvexHull returns the smallest convex polygon that contains all of the points, in clockwise
+// order with the y-axis pointing down. Points that lie on the edges of the hull are omitted.
+//
+// The points of a sprite are typically the corners of its visible pixels, which can be reduced to
+// the corners of the leftmost and rightmost visible pixels of each row.
+func ConvexHull(points []Point) []Point {
+	sorted := slices.Clone(points)
+	slices.SortFunc(sorted, func(a, b Point) int {
+		if a.X != b.X {
+			return a.X - b.X
+		}
+		return a.Y - b.Y
+	})
+	sorted = slices.Compact(sorted)
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	// Andrew's monotone chain, building the lower and then upper hull.
+	hull := make([]Point, 0, 2*len(sorted))
+	for _, point := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], point) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, point)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], sorted[i]) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, sorted[i])
+	}
+	return hull[:len(hull)-1]
+}
+
+// triangulate divides a simple polygon into triangles by ear clipping, returning the indices of
+// the vertices of each triangle.
+func triangulate(vertices []Point) [][3]int {
+	indices := make([]int, len(vertices))
+	for i := range indices {
+		indices[i] = i
+	}
+	winding := 1
+	if signedArea(vertices) < 0 {
+		winding = -1
+	}
+
+	var triangles [][3]int
+	for len(indices) > 3 {
+		clipped := false
+		for i := range indices {
+			prev, curr, next := indices[(i+len(indices)-1)%len(indices)], indices[i], indices[(i+1)%len(indices)]
+			a, b, c := vertices[prev], vertices[curr], vertices[next]
+			turn := cross(a, b, c) * winding
+			if turn < 0 {
+				continue
+			}
+			if turn > 0 {
+				if containsVertex(vertices, indices, a, b, c) {
+					continue
+				}
+				triangles = append(triangles, [3]int{prev, curr, next})
+			}
+
+			// Collinear vertices are removed without producing a triangle.
+			indices = slices.Delete(indices, i, i+1)
+			clipped = true
+			break
+		}
+
+		// The polygon intersects itself, so divide the remainder into a fan instead.
+		if !clipped {
+			for i := 1; i < len(indices)-1; i++ {
+				triangles = append(triangles, [3]int{indices[0], indices[i], indices[i+1]})
+			}
+			return triangles
+		}
+	}
+
+	if len(indices) == 3 && cross(vertices[indices[0]], vertices[indices[1]], vertices[indices[2]]) != 0 {
+		triangles = append(triangles, [3]int{indices[0], indices[1], indices[2]})
+	}
+	return triangles
+}
+
+// containsVertex tests whether any of the remaining vertices other than the corners of the
+// triangle lie within it.
+func containsVertex(vertices []Point, indices []int, a, b, c Point) bool {
+	for _, index := range indices {
+		v := vertices[index]
+		if v == a || v == b || v == c {
+			continue
+		}
+		d1, d2, d3 := cross(a, b, v), cross(b, c, v), cross(c, a, v)
+		negative := d1 < 0 || d2 < 0 || d3 < 0
+		positive := d1 > 0 || d2 > 0 || d3 > 0
+		if !(negative && positive) {
+			return true
+		}
+	}
+	return false
+}
+
+// vim: ts=4
@@ -0,0 +1,140 @@
+package rectpack
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// checkPack packs the sizes with the configuration, and verifies the properties that every
+// layout must have regardless of how well it is packed:
+//
+//   - No rectangles or their padding overlap, and all are within bounds.
+//   - Each size is either packed with its ID and dimensions, or returned as unpacked, but never
+//     both and never lost.
+//   - The used area is the total area of the cells of the packed rectangles.
+//
+// The same properties are verified again after repacking everything at once.
+func checkPack(t *testing.T, heuristic Heuristic, padding int, flip, online bool, sizes []Size) {
+	t.Helper()
+	packer, err := NewPacker(128, 128, heuristic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packer.Padding = padding
+	packer.Online = online
+	packer.AllowFlip(flip)
+
+	var unpacked []Size
+	if online {
+		for _, size := range sizes {
+			unpacked = append(unpacked, packer.Insert(size)...)
+		}
+	} else {
+		packer.Insert(slices.Clone(sizes)...)
+		packer.Pack()
+		unpacked = slices.Clone(packer.Unpacked())
+	}
+	checkLayout(t, packer, sizes, unpacked, flip)
+
+	// Sizes that failed offline are staged again, but those that failed online were never staged.
+	packer.RepackAll()
+	if online {
+		unpacked = append(unpacked, packer.Unpacked()...)
+	} else {
+		unpacked = packer.Unpacked()
+	}
+	checkLayout(t, packer, sizes, unpacked, flip)
+}
+
+// checkLayout verifies the properties of the layout of the packer, see checkPack for details.
+// The unpacked sizes are those that were not packed.
+func checkLayout(t *testing.T, packer *Packer, sizes, unpacked []Size, flip bool) {
+	t.Helper()
+	name := packer.heuristic.String()
+
+	// Every size that was not returned as unpacked must be packed with matching dimensions.
+	failed := make(map[int]bool, len(unpacked))
+	for _, size := range unpacked {
+		if failed[size.ID] {
+			t.Errorf("%s: size %d returned as unpacked more than once", name, size.ID)
+		}
+		failed[size.ID] = true
+	}
+	expected := slices.DeleteFunc(slices.Clone(sizes), func(size Size) bool { return failed[size.ID] })
+	for _, violation := range packer.Validate(expected) {
+		t.Errorf("%s: %s", name, violation.String())
+	}
+
+	bounds := packer.algo.MaxSize()
+	area := 0
+	for _, rect := range packer.Rects() {
+		if failed[rect.ID] {
+			t.Errorf("%s: size %d is packed and returned as unpacked", name, rect.ID)
+		}
+		if rect.Flipped && !flip {
+			t.Errorf("%s: rectangle %d flipped when flipping is disabled", name, rect.ID)
+		}
+
+		padding := rect.Options.padding(packer.Padding)
+		width := min(rect.Width+padding, bounds.Width-rect.X)
+		height := min(rect.Height+padding, bounds.Height-rect.Y)
+		area += width * height
+	}
+
+	if used := packer.algo.UsedArea(); used != area {
+		t.Errorf("%s: expected used area of %d, got %d", name, area, used)
+	}
+	if ratio := float64(area) / float64(bounds.Width*bounds.Height); packer.Used(false) != ratio {
+		t.Errorf("%s: expected used ratio of %v, got %v", name, ratio, packer.Used(false))
+	}
+}
+
+func TestProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, heuristic := range validHeuristics() {
+		for _, padding := range []int{0, 1, 3} {
+			for _, flip := range []bool{false, true} {
+				for _, online := range []bool{false, true} {
+					// Include some sizes that are too large to ever fit.
+					sizes := make([]Size, 48)
+					for i := range sizes {
+						sizes[i] = NewSizeID(i, 1+rng.Intn(40), 1+rng.Intn(40))
+					}
+					sizes[0].Width = 129
+					sizes[1].Height = 200
+					checkPack(t, heuristic, padding, flip, online, sizes)
+				}
+			}
+		}
+	}
+}
+
+// FuzzPack packs arbitrary sizes with an arbitrary configuration. The first three bytes choose
+// the heuristic, padding, and flip/online modes, and each following pair of bytes is the width
+// and height of a size.
+func FuzzPack(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 10, 20, 30, 40, 50, 60, 70, 80})
+	f.Add([]byte{5, 0, 3, 128, 1, 1, 128, 64, 64, 64, 64, 64, 64, 64, 64})
+	f.Add([]byte{20, 2, 1, 16, 16, 16, 16, 16, 16, 32, 8, 8, 32})
+	f.Add([]byte{50, 4, 2, 3, 100, 100, 3, 40, 40, 9, 9, 1, 1})
+
+	heuristics := validHeuristics()
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 3 {
+			return
+		}
+		heuristic := heuristics[int(data[0])%len(heuristics)]
+		padding := int(data[1] % 8)
+		flip, online := data[2]&1 != 0, data[2]&2 != 0
+
+		sizes := make([]Size, 0, (len(data)-3)/2)
+		for i := 3; i+1 < len(data); i += 2 {
+			// Sizes range up to twice the bin size, so that some never fit.
+			sizes = append(sizes, NewSizeID(len(sizes), 1+int(data[i]), 1+int(data[i+1])))
+		}
+		checkPack(t, heuristic, padding, flip, online, sizes)
+	})
+}
+
+// vim: ts=4
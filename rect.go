@@ -45,6 +45,34 @@ type Size struct {
 	Height int `json:"height"`
 	// ID is a user-defined identifier that can be used to differentiate this instance from others.
 	ID int `json:"-"`
+	// Options contains settings that apply only to packing this size.
+	Options SizeOptions `json:"-"`
+}
+
+// SizeOptions contains settings for packing an individual size, which take precedence over the
+// settings of the packer. All algorithms honor them when scoring and placing a size, though
+// they are not applied to rectangles that are placed or reserved at a fixed location.
+type SizeOptions struct {
+	// NoFlip prevents the size from being flipped/rotated, even when the packer allows it. This
+	// is useful for images that must retain their orientation, such as those containing text.
+	//
+	// Default: false
+	NoFlip bool `json:"noFlip,omitempty"`
+	// Padding overrides the padding of the packer for this size when greater than 0, such as to
+	// provide a wider gutter for mipmapped tiles.
+	//
+	// Default: 0
+	Padding int `json:"padding,omitempty"`
+	// AlignX restricts the X coordinate of the packed rectangle to a multiple of the value, such
+	// as 4 for block-compressed texture formats. Values of 1 or less have no effect.
+	//
+	// Default: 0
+	AlignX int `json:"alignX,omitempty"`
+	// AlignY restricts the Y coordinate of the packed rectangle to a multiple of the value.
+	// Values of 1 or less have no effect.
+	//
+	// Default: 0
+	AlignY int `json:"alignY,omitempty"`
 }
 
 // NewSize creates a new size with specified dimensions.
@@ -101,6 +129,9 @@ type Rect struct {
 	// Flipped indicates if a rectangle has been flipped to achieve a better fit while
 	// being packed. Only relevant when the packer has AllowFlip enabled.
 	Flipped bool `json:"flipped,omitempty"`
+	// Bin is the index of the bin the rectangle was packed into. Only relevant when packing
+	// with a MultiPacker, otherwise it is always 0.
+	Bin int `json:"bin,omitempty"`
 }
 
 // NewRect initialzies a new rectangle using the specified point and size values.
@@ -114,7 +145,7 @@ func NewRect(x, y, w, h int) Rect {
 // NewRectLTRB initializes a new rectangle using  the specified left/top/right/bottom values.
 func NewRectLTRB(l, t, r, b int) Rect {
 	return Rect{
-		Point: Point{X: l, Y: r},
+		Point: Point{X: l, Y: t},
 		Size:  Size{Width: r - l, Height: b - t},
 	}
 }
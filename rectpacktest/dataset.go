@@ -0,0 +1,188 @@
+package rectpacktest
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// Dataset is a distribution of sizes resembling a real workload, used to benchmark heuristics
+// and compare the quality of their results.
+type Dataset struct {
+	// Name is a short name describing the dataset.
+	Name string
+	// Generate returns the sizes of the dataset with sequential IDs starting at 0, drawing any
+	// random values from rng.
+	Generate func(rng *rand.Rand) []rectpack.Size
+}
+
+// Sizes returns the sizes of the dataset. The same seed always produces the same sizes.
+func (d *Dataset) Sizes(seed int64) []rectpack.Size {
+	return d.Generate(rand.New(rand.NewSource(seed)))
+}
+
+// Heuristics contains the preset heuristics, which are those compared by default.
+var Heuristics = []rectpack.Heuristic{
+	rectpack.MaxRectsBSSF,
+	rectpack.MaxRectsBLSF,
+	rectpack.MaxRectsBAF,
+	rectpack.MaxRectsBL,
+	rectpack.MaxRectsCP,
+	rectpack.SkylineBLF,
+	rectpack.SkylineMinWaste,
+	rectpack.GuillotineBSSF,
+	rectpack.GuillotineBLSF,
+	rectpack.GuillotineBAF,
+	rectpack.GuillotineWSSF,
+	rectpack.GuillotineWLSF,
+	rectpack.GuillotineWAF,
+	rectpack.ShelfNextFit,
+	rectpack.ShelfFirstFit,
+	rectpack.ShelfBestAreaFit,
+	rectpack.ShelfBestWidthFit,
+	rectpack.ShelfBestHeightFit,
+	rectpack.ShelfWorstWidthFit,
+	rectpack.ShelfBestAreaFit | rectpack.WasteMap,
+	rectpack.BinaryTree,
+}
+
+// Datasets contains the standard datasets, which are sized to fill one or more bins of
+// 1024x1024.
+var Datasets = []Dataset{
+	{Name: "uniform", Generate: uniform},
+	{Name: "mixed", Generate: mixed},
+	{Name: "strips", Generate: strips},
+	{Name: "icons", Generate: icons},
+	{Name: "glyphs", Generate: glyphs},
+}
+
+// between returns a random value in the range of lo to hi, inclusive.
+func between(rng *rand.Rand, lo, hi int) int {
+	return lo + rng.Intn(hi-lo+1)
+}
+
+// uniform contains sizes with each side chosen independently between 8 and 96.
+func uniform(rng *rand.Rand) []rectpack.Size {
+	sizes := make([]rectpack.Size, 400)
+	for i := range sizes {
+		sizes[i] = rectpack.NewSizeID(i, between(rng, 8, 96), between(rng, 8, 96))
+	}
+	return sizes
+}
+
+// mixed contains many small sizes and few large ones, such as the sprites and backgrounds of a
+// game.
+func mixed(rng *rand.Rand) []rectpack.Size {
+	sizes := make([]rectpack.Size, 800)
+	for i := range sizes {
+		if rng.Intn(20) == 0 {
+			sizes[i] = rectpack.NewSizeID(i, between(rng, 64, 384), between(rng, 64, 384))
+		} else {
+			sizes[i] = rectpack.NewSizeID(i, between(rng, 4, 32), between(rng, 4, 32))
+		}
+	}
+	return sizes
+}
+
+// strips contains long and thin sizes in either orientation, such as the borders of a user
+// interface or the frames of a scrolling animation.
+func strips(rng *rand.Rand) []rectpack.Size {
+	sizes := make([]rectpack.Size, 500)
+	for i := range sizes {
+		long, short := between(rng, 64, 768), between(rng, 2, 16)
+		if rng.Intn(4) == 0 {
+			long, short = short, long
+		}
+		sizes[i] = rectpack.NewSizeID(i, long, short)
+	}
+	return sizes
+}
+
+// icons contains squares with sides that are a power of two, with smaller sizes being more
+// common.
+func icons(rng *rand.Rand) []rectpack.Size {
+	sizes := make([]rectpack.Size, 600)
+	for i := range sizes {
+		// Each size is half as likely as the one before it.
+		side := 16
+		for side < 128 && rng.Intn(2) == 0 {
+			side <<= 1
+		}
+		sizes[i] = rectpack.NewSizeID(i, side, side)
+	}
+	return sizes
+}
+
+// glyphs contains the printable ASCII characters of a font rendered at several sizes, where
+// glyphs share a line height and vary in width.
+func glyphs(rng *rand.Rand) []rectpack.Size {
+	var sizes []rectpack.Size
+	for _, em := range []int{12, 16, 24, 32, 48, 64} {
+		// Each character has the same proportions at every size.
+		shapes := rand.New(rand.NewSource(rng.Int63()))
+		for char := ' ' + 1; char <= '~'; char++ {
+			width := max(1, em*between(shapes, 25, 90)/100)
+			height := max(1, em*between(shapes, 60, 125)/100)
+			sizes = append(sizes, rectpack.NewSizeID(len(sizes), width, height))
+		}
+	}
+	return sizes
+}
+
+// Result is the outcome of packing a dataset with a MultiPacker.
+type Result struct {
+	// Heuristic is the heuristic of the packer.
+	Heuristic rectpack.Heuristic
+	// Sort is the name of the sort function of the packer.
+	Sort string
+	// Count is the number of sizes that were packed.
+	Count int
+	// Bins is the number of bins that were opened.
+	Bins int
+	// Occupancy is the ratio of the area that is used to the area each bin requires to contain
+	// its rectangles, where a value of 1.0 means there is no waste.
+	Occupancy float64
+	// Elapsed is the time that was spent packing, excluding setup.
+	Elapsed time.Duration
+}
+
+// PerInsert returns the average time spent packing each size.
+func (r *Result) PerInsert() time.Duration {
+	if r.Count == 0 {
+		return 0
+	}
+	return r.Elapsed / time.Duration(r.Count)
+}
+
+// Measure packs the sizes into as many bins of the specified size as needed, using the heuristic
+// and the sort function with the specified name, as accepted by rectpack.ParseSortFunc. Flipping
+// is enabled when flip is set. Sizes that do not fit into an empty bin are not counted.
+func Measure(sizes []rectpack.Size, width, height int, heuristic rectpack.Heuristic, sort string, flip bool) (Result, error) {
+	compare, err := rectpack.ParseSortFunc(sort)
+	if err != nil {
+		return Result{}, err
+	}
+	packer, err := rectpack.NewMultiPacker(width, height, heuristic)
+	if err != nil {
+		return Result{}, err
+	}
+	packer.Sorter(compare, false)
+	packer.AllowFlip(flip)
+
+	packer.Insert(sizes...)
+	start := time.Now()
+	packer.Pack()
+	elapsed := time.Since(start)
+
+	return Result{
+		Heuristic: heuristic,
+		Sort:      sort,
+		Count:     len(sizes) - len(packer.Unpacked()),
+		Bins:      len(packer.Bins()),
+		Occupancy: packer.Used(true),
+		Elapsed:   elapsed,
+	}, nil
+}
+
+// vim: ts=4
@@ -0,0 +1,399 @@
+// Package rectpacktest implements support for testing implementations of rectpack.Algorithm.
+package rectpacktest
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/ForeverZer0/rectpack"
+)
+
+// Factory creates a new instance of the algorithm being tested, with the specified maximum size
+// and flipping disabled.
+type Factory func(width, height int) rectpack.Algorithm
+
+// binSize is the maximum width and height used for most tests.
+const binSize = 256
+
+// TestAlgorithm runs a suite of tests that verify an algorithm conforms to the requirements of
+// the rectpack.Algorithm interface, reporting any failures with t. Each test is run as a subtest
+// with a new instance of the algorithm.
+//
+// The suite does not judge the quality of packing, only that it is correct: rectangles are
+// within the maximum extents and never overlap, sizes and IDs are preserved, the used area is
+// accounted for consistently, and removed space can be reused.
+func TestAlgorithm(t *testing.T, factory Factory) {
+	t.Run("Reset", func(t *testing.T) { testReset(t, factory) })
+	for _, padding := range []int{0, 2} {
+		for _, flip := range []bool{false, true} {
+			name := "Insert"
+			if padding != 0 {
+				name += "/Padding"
+			}
+			if flip {
+				name += "/Flip"
+			}
+			t.Run(name, func(t *testing.T) { testInsert(t, factory, padding, flip) })
+		}
+	}
+	t.Run("Options", func(t *testing.T) { testOptions(t, factory) })
+	t.Run("Oversized", func(t *testing.T) { testOversized(t, factory) })
+	t.Run("Remove", func(t *testing.T) { testRemove(t, factory) })
+	t.Run("Place", func(t *testing.T) { testPlace(t, factory) })
+	t.Run("Reserve", func(t *testing.T) { testReserve(t, factory) })
+}
+
+// randomSizes returns a deterministic set of sizes with sequential IDs starting at 0.
+func randomSizes(count, minSize, maxSize int) []rectpack.Size {
+	rng := rand.New(rand.NewSource(1))
+	sizes := make([]rectpack.Size, count)
+	for i := range sizes {
+		w := minSize + rng.Intn(maxSize-minSize+1)
+		h := minSize + rng.Intn(maxSize-minSize+1)
+		sizes[i] = rectpack.NewSizeID(i, w, h)
+	}
+	return sizes
+}
+
+// cell returns the area occupied by a packed rectangle with padding, which is the padding of the
+// rectangle itself when specified.
+func cell(rect rectpack.Rect, padding int) rectpack.Rect {
+	if rect.Options.Padding > 0 {
+		padding = rect.Options.Padding
+	}
+	if padding > 0 {
+		rect.Width += padding
+		rect.Height += padding
+	}
+	return rect
+}
+
+// checkLayout verifies that the packed rectangles lie within the maximum extents, and that their
+// cells do not overlap each other or any reserved rectangle. Rectangles that were placed may
+// extend their padding beyond the maximum extents, so only the rectangle itself is required to
+// be within bounds.
+func checkLayout(t *testing.T, algo rectpack.Algorithm, padding int) {
+	t.Helper()
+	bounds := algo.MaxSize()
+	rects := algo.Rects()
+	for i, rect := range rects {
+		if rect.IsEmpty() || rect.X < 0 || rect.Y < 0 || rect.Right() > bounds.Width || rect.Bottom() > bounds.Height {
+			t.Errorf("rectangle %d %s is outside of the maximum extents %s", rect.ID, rect.String(), bounds.String())
+		}
+
+		a := cell(rect, padding)
+		for _, other := range rects[i+1:] {
+			if b := cell(other, padding); a.Intersects(b) {
+				t.Errorf("rectangles %d %s and %d %s overlap", rect.ID, rect.String(), other.ID, other.String())
+			}
+		}
+		for _, reserved := range algo.Reserved() {
+			if a.Intersects(reserved) {
+				t.Errorf("rectangle %d %s overlaps reserved %s", rect.ID, rect.String(), reserved.String())
+			}
+		}
+	}
+}
+
+// checkUsed verifies that the used area is the total area of the cells of the packed rectangles,
+// limited to the maximum extents, and the reserved rectangles.
+func checkUsed(t *testing.T, algo rectpack.Algorithm, padding int) {
+	t.Helper()
+	bounds := algo.MaxSize()
+	area := 0
+	for _, rect := range algo.Rects() {
+		c := cell(rect, padding)
+		c.Width = min(c.Width, bounds.Width-c.X)
+		c.Height = min(c.Height, bounds.Height-c.Y)
+		area += c.Area()
+	}
+	for _, rect := range algo.Reserved() {
+		area += rect.Area()
+	}
+
+	if used := algo.UsedArea(); used != area {
+		t.Errorf("expected used area of %d, got %d", area, used)
+	}
+	if ratio := float64(area) / float64(bounds.Width*bounds.Height); algo.Used() != ratio {
+		t.Errorf("expected used ratio of %v, got %v", ratio, algo.Used())
+	}
+}
+
+func testReset(t *testing.T, factory Factory) {
+	algo := factory(binSize, binSize/2)
+	if size := algo.MaxSize(); size.Width != binSize || size.Height != binSize/2 {
+		t.Fatalf("expected maximum size of %dx%d, got %s", binSize, binSize/2, size.String())
+	}
+	if len(algo.Rects()) != 0 || len(algo.Reserved()) != 0 || algo.UsedArea() != 0 || algo.Used() != 0 {
+		t.Fatal("new algorithm is not empty")
+	}
+
+	algo.Insert(0, randomSizes(8, 4, 16)...)
+	algo.Reset(binSize/2, binSize)
+	if size := algo.MaxSize(); size.Width != binSize/2 || size.Height != binSize {
+		t.Fatalf("expected maximum size of %dx%d after reset, got %s", binSize/2, binSize, size.String())
+	}
+	if len(algo.Rects()) != 0 || algo.UsedArea() != 0 {
+		t.Fatal("algorithm is not empty after reset")
+	}
+
+	// The full area must be available after a reset.
+	if failed := algo.Insert(0, rectpack.NewSizeID(1, binSize/2, binSize)); len(failed) != 0 {
+		t.Error("cannot fit a rectangle of the maximum size after reset")
+	}
+}
+
+func testInsert(t *testing.T, factory Factory, padding int, flip bool) {
+	algo := factory(binSize, binSize)
+	algo.AllowFlip(flip)
+
+	// More sizes than can possibly fit, so that some are returned.
+	sizes := randomSizes(300, 4, 32)
+	failed := algo.Insert(padding, slices.Clone(sizes)...)
+	rects := algo.Rects()
+	if len(rects) == 0 {
+		t.Fatal("no rectangles were packed")
+	}
+	if len(rects)+len(failed) != len(sizes) {
+		t.Fatalf("%d packed and %d failed, expected a total of %d", len(rects), len(failed), len(sizes))
+	}
+
+	seen := make(map[int]bool, len(sizes))
+	for _, size := range failed {
+		seen[size.ID] = true
+	}
+	for _, rect := range rects {
+		if rect.ID < 0 || rect.ID >= len(sizes) || seen[rect.ID] {
+			t.Errorf("rectangle has an unexpected or duplicate ID %d", rect.ID)
+			continue
+		}
+		seen[rect.ID] = true
+
+		size := sizes[rect.ID]
+		switch {
+		case !rect.Flipped && rect.Width == size.Width && rect.Height == size.Height:
+		case rect.Flipped && flip && rect.Width == size.Height && rect.Height == size.Width:
+		default:
+			t.Errorf("rectangle %d %s does not match size %s (flipped: %t)", rect.ID, rect.String(), size.String(), rect.Flipped)
+		}
+	}
+
+	checkLayout(t, algo, padding)
+	checkUsed(t, algo, padding)
+}
+
+func testOptions(t *testing.T, factory Factory) {
+	const padding = 1
+
+	algo := factory(binSize, binSize)
+	algo.AllowFlip(true)
+
+	sizes := randomSizes(200, 4, 32)
+	for i := range sizes {
+		switch i % 4 {
+		case 0:
+			sizes[i].Options.NoFlip = true
+		case 1:
+			sizes[i].Options.Padding = 3
+		case 2:
+			sizes[i].Options.AlignX = 4
+			sizes[i].Options.AlignY = 8
+		}
+	}
+
+	failed := algo.Insert(padding, slices.Clone(sizes)...)
+	rects := algo.Rects()
+	if len(rects) == 0 {
+		t.Fatal("no rectangles were packed")
+	}
+	if len(rects)+len(failed) != len(sizes) {
+		t.Fatalf("%d packed and %d failed, expected a total of %d", len(rects), len(failed), len(sizes))
+	}
+	for _, rect := range rects {
+		opts := sizes[rect.ID].Options
+		if rect.Options != opts {
+			t.Errorf("rectangle %d does not have the options of its size", rect.ID)
+		}
+		if opts.NoFlip && rect.Flipped {
+			t.Errorf("rectangle %d was flipped", rect.ID)
+		}
+		if opts.AlignX > 1 && rect.X%opts.AlignX != 0 || opts.AlignY > 1 && rect.Y%opts.AlignY != 0 {
+			t.Errorf("rectangle %d %s is not aligned to %dx%d", rect.ID, rect.String(), opts.AlignX, opts.AlignY)
+		}
+	}
+	checkLayout(t, algo, padding)
+	checkUsed(t, algo, padding)
+
+	// The padding of the rectangle itself is reclaimed when it is removed.
+	for _, rect := range slices.Clone(rects) {
+		if rect.Options.Padding > 0 && !algo.Remove(padding, rect.ID) {
+			t.Fatalf("failed to remove %d", rect.ID)
+		}
+	}
+	checkUsed(t, algo, padding)
+}
+
+func testOversized(t *testing.T, factory Factory) {
+	algo := factory(binSize, binSize)
+	sizes := []rectpack.Size{
+		rectpack.NewSizeID(0, binSize+1, 8),
+		rectpack.NewSizeID(1, 8, binSize+1),
+		rectpack.NewSizeID(2, binSize, binSize),
+	}
+
+	failed := algo.Insert(0, slices.Clone(sizes)...)
+	slices.SortFunc(failed, func(a, b rectpack.Size) int { return a.ID - b.ID })
+	if len(failed) != 2 || failed[0].ID != 0 || failed[1].ID != 1 {
+		t.Errorf("expected sizes exceeding the maximum extents to fail, got %v", failed)
+	}
+	if rects := algo.Rects(); len(rects) != 1 || rects[0].ID != 2 {
+		t.Errorf("expected a rectangle of the maximum size to be packed, got %v", rects)
+	}
+}
+
+func testRemove(t *testing.T, factory Factory) {
+	const padding = 2
+
+	// Fill the bin completely, so that the only space available is what gets removed.
+	algo := factory(binSize, binSize)
+	side := binSize/2 - padding
+	for i := 0; i < 4; i++ {
+		if failed := algo.Insert(padding, rectpack.NewSizeID(i, side, side)); len(failed) != 0 {
+			t.Fatal("cannot fill the bin")
+		}
+	}
+
+	last := algo.Rects()[3]
+	used := algo.UsedArea()
+	if !algo.Remove(padding, last.ID) {
+		t.Fatalf("failed to remove %d", last.ID)
+	}
+	if algo.Remove(padding, last.ID) {
+		t.Fatalf("removed %d twice", last.ID)
+	}
+	if algo.Remove(padding, 100) {
+		t.Fatal("removed an ID that was never packed")
+	}
+	for _, rect := range algo.Rects() {
+		if rect.ID == last.ID {
+			t.Fatalf("removed rectangle %d is still packed", last.ID)
+		}
+	}
+	checkUsed(t, algo, padding)
+
+	if failed := algo.Insert(padding, rectpack.NewSizeID(4, side, side)); len(failed) != 0 {
+		t.Fatal("space of removed rectangle was not reclaimed")
+	}
+	if algo.UsedArea() != used {
+		t.Errorf("expected used area of %d, got %d", used, algo.UsedArea())
+	}
+	checkLayout(t, algo, padding)
+}
+
+func testPlace(t *testing.T, factory Factory) {
+	const padding = 2
+
+	algo := factory(binSize, binSize)
+	half := binSize / 2
+	placed := []rectpack.Rect{
+		rectpack.NewRect(0, 0, half-padding, half-padding),
+		rectpack.NewRect(half, 0, half-padding, half-padding),
+		rectpack.NewRect(0, half, half-padding, half-padding),
+		rectpack.NewRect(10, 10, 4, 4),
+		// Only overlaps the padding of the first rectangle.
+		rectpack.NewRect(half-1, half-1, 1, 1),
+		rectpack.NewRect(binSize-8, binSize-8, 16, 16),
+	}
+	for i := range placed {
+		placed[i].ID = i
+	}
+
+	failed := algo.Place(padding, placed...)
+	if len(failed) != 3 || failed[0].ID != 3 || failed[1].ID != 4 || failed[2].ID != 5 {
+		t.Fatalf("expected overlapping and out of bounds rectangles to fail, got %v", failed)
+	}
+	rects := algo.Rects()
+	if len(rects) != 3 {
+		t.Fatalf("expected 3 placed rectangles, got %d", len(rects))
+	}
+	for i, rect := range rects {
+		if rect != placed[i] {
+			t.Errorf("expected placed rectangle %s, got %s", placed[i].String(), rect.String())
+		}
+	}
+	checkUsed(t, algo, padding)
+
+	// The only remaining space is the bottom-right quarter.
+	if failed := algo.Insert(padding, rectpack.NewSizeID(6, half-padding, half-padding)); len(failed) != 0 {
+		t.Fatal("cannot fit rectangle into remaining space")
+	}
+	if failed := algo.Insert(padding, rectpack.NewSizeID(7, 1, 1)); len(failed) != 1 {
+		t.Error("packed rectangle into a full bin")
+	}
+	checkLayout(t, algo, padding)
+	checkUsed(t, algo, padding)
+}
+
+func testReserve(t *testing.T, factory Factory) {
+	const padding = 2
+
+	algo := factory(binSize, binSize)
+	half := binSize / 2
+	reserved := rectpack.NewRect(0, 0, half, half)
+	failed := algo.Reserve(padding, reserved, rectpack.NewRect(half/2, half/2, 8, 8), rectpack.NewRect(binSize-8, 0, 16, 16))
+	if len(failed) != 2 || len(algo.Reserved()) != 1 {
+		t.Fatalf("expected overlapping and out of bounds reservations to fail, got %v", failed)
+	}
+	if len(algo.Rects()) != 0 {
+		t.Fatal("reserved rectangles must not be included in packed rectangles")
+	}
+	checkUsed(t, algo, padding)
+
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < 3; i++ {
+			if failed := algo.Insert(padding, rectpack.NewSizeID(i, half-padding, half-padding)); len(failed) != 0 {
+				t.Fatal("cannot fit rectangles around reservation")
+			}
+		}
+		checkLayout(t, algo, padding)
+		checkUsed(t, algo, padding)
+
+		// Reservations are retained when the algorithm is reset.
+		algo.Reset(binSize, binSize)
+		if len(algo.Reserved()) != 1 {
+			t.Fatal("reservation not retained after reset")
+		}
+		checkUsed(t, algo, padding)
+	}
+
+	if algo.Unreserve(padding, rectpack.NewRect(0, 0, 8, 8)) {
+		t.Error("removed reservation that does not exist")
+	}
+	if !algo.Unreserve(padding, reserved) || len(algo.Reserved()) != 0 {
+		t.Fatal("failed to remove reservation")
+	}
+	checkUsed(t, algo, padding)
+
+	// The area of the reservation is free again, leaving room for all four quarters.
+	for i := 0; i < 4; i++ {
+		if failed := algo.Insert(padding, rectpack.NewSizeID(i, half-padding, half-padding)); len(failed) != 0 {
+			t.Fatal("cannot fit rectangles into area that was reserved")
+		}
+	}
+	checkLayout(t, algo, padding)
+	checkUsed(t, algo, padding)
+
+	// Reservations must not overlap the padding of packed rectangles.
+	algo.Reset(binSize, binSize)
+	if failed := algo.Place(padding, rectpack.NewRect(0, 0, 8, 8)); len(failed) != 0 {
+		t.Fatal("cannot place rectangle")
+	}
+	failed = algo.Reserve(padding, rectpack.NewRect(8, 0, 1, 1), rectpack.NewRect(10, 0, 1, 1))
+	if len(failed) != 1 || failed[0].X != 8 {
+		t.Errorf("expected reservation within padding of packed rectangle to fail, got %v", failed)
+	}
+	checkUsed(t, algo, padding)
+}
+
+// vim: ts=4
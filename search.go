@@ -0,0 +1,167 @@
+package rectpack
+
+import (
+	"cmp"
+	"runtime"
+	"sync"
+)
+
+// Objective describes the criteria used to compare the results of different packing
+// configurations against each other.
+type Objective uint8
+
+const (
+	// ObjectiveBins prefers the result that uses the fewest bins, using the smallest total area
+	// to break ties.
+	ObjectiveBins Objective = iota
+	// ObjectiveArea prefers the result with the smallest total area required to contain the
+	// packed rectangles of all bins.
+	ObjectiveArea
+	// ObjectiveUsed prefers the result with the highest ratio of used surface area relative to
+	// the current size of all bins.
+	ObjectiveUsed
+)
+
+// SearchOptions contains the settings used by Search when packing each candidate.
+type SearchOptions struct {
+	// Objective is the criteria used to select the winning candidate.
+	//
+	// Default: ObjectiveBins
+	Objective Objective
+	// Padding defines the amount of empty space to place around rectangles.
+	//
+	// Default: 0
+	Padding int
+	// AllowFlip indicates if rectangles can be flipped/rotated to provide better placement.
+	//
+	// Default: false
+	AllowFlip bool
+	// Select is the policy used to choose which open bin rectangles are packed into.
+	//
+	// Default: BinFirstFit
+	Select BinSelect
+	// MaxBins limits the number of bins that can be opened. Values of 0 or less indicates that
+	// there is no limit.
+	//
+	// Default: 0
+	MaxBins int
+	// Workers is the maximum number of candidates that are packed concurrently. Values of 0 or
+	// less indicates that the value of runtime.GOMAXPROCS will be used.
+	//
+	// Default: 0
+	Workers int
+}
+
+// SearchResult describes the winning configuration of a search.
+type SearchResult struct {
+	// Heuristic is the heuristics used to pack the result.
+	Heuristic Heuristic
+	// Sort is the comparer used to sort the sizes before packing.
+	Sort SortFunc
+	// SortName is a short name describing the comparer, such as "area" or "perimeter".
+	SortName string
+	// Reverse indicates if the sort order was reversed.
+	Reverse bool
+	// Packer contains the packed result. Any sizes that could not be packed are staged and can
+	// be retrieved with Unpacked.
+	Packer *MultiPacker
+}
+
+// Search packs the sizes using every valid combination of heuristics, sort function, and
+// sort direction, returning the configuration that performs best for the objective. Results
+// that are able to pack more rectangles are always preferred.
+//
+// The candidates are packed concurrently, and the result is deterministic regardless of the
+// number of workers used.
+func Search(maxWidth, maxHeight int, sizes []Size, opts SearchOptions) (*SearchResult, error) {
+	var candidates []*SearchResult
+	for _, heuristic := range validHeuristics() {
+		for _, sorter := range sortFuncs {
+			for _, reverse := range []bool{false, true} {
+				candidates = append(candidates, &SearchResult{
+					Heuristic: heuristic,
+					Sort:      sorter.compare,
+					SortName:  sorter.name,
+					Reverse:   reverse,
+				})
+			}
+		}
+	}
+
+	// Ensure the dimensions are valid before spawning any workers.
+	if _, err := NewMultiPacker(maxWidth, maxHeight, candidates[0].Heuristic); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var wg sync.WaitGroup
+	jobs := make(chan *SearchResult)
+	for i := 0; i < min(workers, len(candidates)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for candidate := range jobs {
+				packer, _ := NewMultiPacker(maxWidth, maxHeight, candidate.Heuristic)
+				packer.Padding = opts.Padding
+				packer.Select = opts.Select
+				packer.MaxBins = opts.MaxBins
+				packer.AllowFlip(opts.AllowFlip)
+				packer.Sorter(candidate.Sort, candidate.Reverse)
+				packer.Insert(sizes...)
+				packer.Pack()
+				candidate.Packer = packer
+			}
+		}()
+	}
+
+	for _, candidate := range candidates {
+		jobs <- candidate
+	}
+	close(jobs)
+	wg.Wait()
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if compareResults(candidate.Packer, best.Packer, opts.Objective) < 0 {
+			best = candidate
+		}
+	}
+	return best, nil
+}
+
+// compareResults compares two packing results by the given objective, returning a negative
+// value when a is better than b, a positive value when b is better than a, or 0 if they are
+// equivalent. Results that are able to pack more rectangles are always preferred.
+func compareResults(a, b *MultiPacker, objective Objective) int {
+	if c := cmp.Compare(len(a.Unpacked()), len(b.Unpacked())); c != 0 {
+		return c
+	}
+
+	switch objective {
+	case ObjectiveArea:
+		return cmp.Compare(totalArea(a), totalArea(b))
+	case ObjectiveUsed:
+		return cmp.Compare(b.Used(true), a.Used(true))
+	default: // ObjectiveBins
+		if c := cmp.Compare(len(a.Bins()), len(b.Bins())); c != 0 {
+			return c
+		}
+		return cmp.Compare(totalArea(a), totalArea(b))
+	}
+}
+
+// totalArea returns the sum of the area required to contain the packed rectangles of each bin.
+func totalArea(p *MultiPacker) int {
+	var area int
+	for _, bin := range p.Bins() {
+		size := bin.Size()
+		area += size.Area()
+	}
+	return area
+}
+
+// vim: ts=4
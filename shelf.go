@@ -0,0 +1,342 @@
+package rectpack
+
+import (
+	"math"
+	"slices"
+)
+
+type shelf struct {
+	StartY   int `json:"y"`
+	Height   int `json:"height"`
+	CurrentX int `json:"x"`
+}
+
+type shelfPack struct {
+	algorithmBase
+	method   Heuristic
+	shelves  []shelf
+	wasteMap *guillotinePack
+}
+
+func newShelf(width, height int, heuristic Heuristic) *shelfPack {
+	var packer shelfPack
+
+	switch heuristic & fitMask {
+	case NextFit, BestAreaFit, BestWidthFit, BestHeightFit, WorstWidthFit:
+		packer.method = heuristic & fitMask
+	default: // FirstFit
+		packer.method = FirstFit
+	}
+
+	if heuristic&WasteMap != 0 {
+		packer.wasteMap = newGuillotine(width, height, BestShortSideFit|SplitMaximizeArea)
+	}
+
+	packer.Reset(width, height)
+	return &packer
+}
+
+func (p *shelfPack) Reset(width, height int) {
+	p.algorithmBase.Reset(width, height)
+	p.shelves = p.shelves[:0]
+
+	if p.wasteMap != nil {
+		// The waste map only ever contains areas of closed shelves.
+		p.wasteMap.Reset(width, height)
+		p.wasteMap.freeRects = p.wasteMap.freeRects[:0]
+	}
+	if len(p.reserved) > 0 {
+		p.rebuild(0)
+	}
+}
+
+func (p *shelfPack) setObserver(observer Observer) {
+	p.algorithmBase.setObserver(observer)
+	if p.wasteMap != nil {
+		p.wasteMap.setObserver(observer)
+	}
+}
+
+func (p *shelfPack) AllowFlip(enabled bool) {
+	p.algorithmBase.AllowFlip(enabled)
+	if p.wasteMap != nil {
+		p.wasteMap.AllowFlip(enabled)
+	}
+}
+
+// Insert packs the sizes in the order they are given, as shelf packing is an online algorithm
+// that gains nothing from considering them all at once.
+func (p *shelfPack) Insert(padding int, sizes ...Size) []Size {
+	failed := sizes[:0]
+	for i, size := range sizes {
+		if p.canceled() {
+			return append(failed, sizes[i:]...)
+		}
+		if !p.insert(padding, size) {
+			failed = append(failed, size)
+		}
+	}
+	return failed
+}
+
+func (p *shelfPack) insert(padding int, size Size) bool {
+	cell := size
+	p.padSize(&cell, padding)
+	width, height, opts := cell.Width, cell.Height, p.options(size.Options)
+	if p.observer != nil {
+		p.scoring = size.ID
+	}
+
+	index := p.findShelf(width, height, opts)
+	if index < 0 {
+		// Try the waste map before opening a new shelf, as it contains space that would
+		// otherwise be lost.
+		if _, ok := p.insertWaste(p.wasteMap, padding, []Size{size}); ok {
+			return true
+		}
+		if index = p.openShelf(padding, width, height, opts); index < 0 {
+			return false
+		}
+		if p.observer != nil {
+			// The size is always placed onto a new shelf, so it was not scored against it.
+			node, _ := p.fitShelf(index, width, height, opts)
+			p.observeShelf(index, node, 0)
+		}
+	}
+
+	node, _ := p.fitShelf(index, width, height, opts)
+	shelf := &p.shelves[index]
+	shelf.CurrentX = node.Right()
+	shelf.Height = max(shelf.Height, node.Bottom()-shelf.StartY)
+	p.usedArea += node.Area()
+
+	unpadRect(&node, size)
+	p.addPacked(node)
+	return true
+}
+
+// fitShelf returns the location of the size on the shelf at the specified index, flipping it if
+// enabled and beneficial. Only the last shelf can grow taller to fit the size. When the size is
+// aligned, the space skipped to align it is included in the height it requires.
+func (p *shelfPack) fitShelf(index, width, height int, opts SizeOptions) (Rect, bool) {
+	shelf := &p.shelves[index]
+	x, y := alignUp(shelf.CurrentX, opts.AlignX), alignUp(shelf.StartY, opts.AlignY)
+	maxHeight := shelf.StartY + shelf.Height - y
+	if index == len(p.shelves)-1 {
+		maxHeight = p.maxHeight - y
+	}
+
+	remaining := p.maxWidth - x
+	upright := width <= remaining && height <= maxHeight
+	flipped := p.canFlip(opts) && height <= remaining && width <= maxHeight
+	if !upright && !flipped {
+		return Rect{}, false
+	}
+
+	// When both orientations fit, prefer the one that grows the shelf the least, and then the
+	// one that uses the least width.
+	if upright && flipped {
+		offset := y - shelf.StartY
+		growth := max(offset+height, shelf.Height) - max(offset+width, shelf.Height)
+		flipped = growth > 0 || (growth == 0 && height < width)
+	}
+
+	node := NewRect(x, y, width, height)
+	if flipped {
+		node.Width, node.Height = height, width
+		node.Flipped = true
+	}
+	return node, true
+}
+
+// findShelf returns the index of the open shelf chosen by the bin-selection method, or -1 if the
+// size does not fit onto any of them.
+func (p *shelfPack) findShelf(width, height int, opts SizeOptions) int {
+	if len(p.shelves) == 0 {
+		return -1
+	}
+	if p.method == NextFit {
+		if node, ok := p.fitShelf(len(p.shelves)-1, width, height, opts); ok {
+			if p.observer != nil {
+				p.observeShelf(len(p.shelves)-1, node, 0)
+			}
+			return len(p.shelves) - 1
+		}
+		return -1
+	}
+
+	bestIndex := -1
+	bestScore := math.MaxInt
+	for i := range p.shelves {
+		node, ok := p.fitShelf(i, width, height, opts)
+		if !ok {
+			continue
+		}
+
+		var score int
+		shelf := &p.shelves[i]
+		switch p.method {
+		case BestAreaFit:
+			score = (p.maxWidth - shelf.CurrentX) * max(shelf.Height, node.Bottom()-shelf.StartY)
+		case BestWidthFit:
+			score = p.maxWidth - node.Right()
+		case BestHeightFit:
+			score = abs(shelf.Height - (node.Bottom() - shelf.StartY))
+		case WorstWidthFit:
+			score = -(p.maxWidth - node.Right())
+		}
+		if p.observer != nil {
+			p.observeShelf(i, node, score)
+		}
+		if p.method == FirstFit {
+			return i
+		}
+
+		if score < bestScore {
+			bestScore = score
+			bestIndex = i
+		}
+	}
+	return bestIndex
+}
+
+// observeShelf notifies the observer that a shelf was scored as a candidate for the size.
+func (p *shelfPack) observeShelf(index int, node Rect, score int) {
+	shelf := &p.shelves[index]
+	area := NewRect(shelf.CurrentX, shelf.StartY, p.maxWidth-shelf.CurrentX, shelf.Height)
+	p.observeScore(area, node.X, node.Y, node.Width, node.Height, node.Flipped, score, 0)
+}
+
+// openShelf starts a new shelf below the last one that can contain the size, returning its index
+// or -1 if there is not enough room. When a waste map is used, the last shelf is closed and its
+// unused area is moved into the waste map.
+func (p *shelfPack) openShelf(padding, width, height int, opts SizeOptions) int {
+	y := 0
+	if len(p.shelves) > 0 {
+		last := &p.shelves[len(p.shelves)-1]
+		y = last.StartY + last.Height
+	}
+
+	// Either orientation may fit, and fitShelf chooses between them once the shelf is open.
+	top := alignUp(y, opts.AlignY)
+	upright := width <= p.maxWidth && top+height <= p.maxHeight
+	flipped := p.canFlip(opts) && height <= p.maxWidth && top+width <= p.maxHeight
+	if !upright && !flipped {
+		return -1
+	}
+
+	if p.wasteMap != nil && len(p.shelves) > 0 {
+		p.closeShelf(len(p.shelves)-1, padding)
+	}
+	p.shelves = append(p.shelves, shelf{StartY: y})
+	return len(p.shelves) - 1
+}
+
+// closeShelf moves the unused area of a shelf into the waste map, so that no more rectangles are
+// placed onto the shelf itself.
+func (p *shelfPack) closeShelf(index, padding int) {
+	shelf := &p.shelves[index]
+	band := NewRect(0, shelf.StartY, p.maxWidth, shelf.Height)
+	shelf.CurrentX = p.maxWidth
+	if band.IsEmpty() {
+		return
+	}
+
+	free := []Rect{band}
+	for _, cell := range p.occupied(padding) {
+		if cell.Intersects(band) {
+			free = subtractRect(free, cell)
+		}
+	}
+	for _, waste := range p.wasteMap.freeRects {
+		if waste.Intersects(band) {
+			free = subtractRect(free, waste)
+		}
+	}
+
+	p.wasteMap.freeRects = append(p.wasteMap.freeRects, free...)
+	p.wasteMap.mergeFreeList()
+}
+
+func (p *shelfPack) Remove(padding, id int) bool {
+	rect, ok := p.remove(id)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(rect, padding)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+func (p *shelfPack) Place(padding int, rects ...Rect) []Rect {
+	failed := p.place(padding, rects, nil)
+	p.rebuild(padding)
+	return failed
+}
+
+// Reserve marks areas of the bin as occupied. Shelves are formed around reserved rectangles, so
+// the area to the left of a reserved rectangle within its shelf is only available to the waste
+// map when one is used.
+func (p *shelfPack) Reserve(padding int, rects ...Rect) []Rect {
+	failed := p.reserve(padding, rects, nil)
+	p.rebuild(padding)
+	return failed
+}
+
+func (p *shelfPack) Unreserve(padding int, rect Rect) bool {
+	reserved, ok := p.unreserve(rect)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(reserved, 0)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+// rebuild recomputes the shelves from the occupied area. Overlapping rectangles are grouped into
+// a shelf that begins at the right edge of the rightmost one, and any gap between shelves
+// becomes an empty shelf. Free areas to the left of the rightmost rectangle are moved into the
+// waste map when one is used, and are otherwise lost.
+func (p *shelfPack) rebuild(padding int) {
+	cells := p.occupied(padding)
+	slices.SortFunc(cells, func(a, b Rect) int { return a.Y - b.Y })
+
+	p.shelves = p.shelves[:0]
+	var waste []Rect
+	y := 0
+	for i := 0; i < len(cells); {
+		top := cells[i].Y
+		if top > y {
+			p.shelves = append(p.shelves, shelf{StartY: y, Height: top - y})
+		}
+
+		bottom, right := cells[i].Bottom(), 0
+		j := i
+		for ; j < len(cells) && cells[j].Y < bottom; j++ {
+			bottom = max(bottom, cells[j].Bottom())
+			right = max(right, cells[j].Right())
+		}
+
+		if p.wasteMap != nil {
+			free := []Rect{NewRect(0, top, right, bottom-top)}
+			for _, cell := range cells[i:j] {
+				free = subtractRect(free, cell)
+			}
+			waste = append(waste, free...)
+		}
+
+		p.shelves = append(p.shelves, shelf{StartY: top, Height: bottom - top, CurrentX: right})
+		y, i = bottom, j
+	}
+
+	if p.wasteMap != nil {
+		p.wasteMap.freeRects = append(p.wasteMap.freeRects[:0], waste...)
+		p.wasteMap.mergeFreeList()
+	}
+}
+
+// vim: ts=4
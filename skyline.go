@@ -6,7 +6,9 @@ import (
 )
 
 type skylineNode struct {
-	X, Y, Width int
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Width int `json:"width"`
 }
 
 type skylinePack struct {
@@ -37,12 +39,36 @@ func (p *skylinePack) Reset(width, height int) {
 	p.skyline = append(p.skyline, skylineNode{X: 0, Y: 0, Width: p.maxWidth})
 
 	if p.wasteMap != nil {
+		// The waste map only ever contains areas below the skyline.
 		p.wasteMap.Reset(width, height)
+		p.wasteMap.freeRects = p.wasteMap.freeRects[:0]
+	}
+	if len(p.reserved) > 0 {
+		p.rebuild(0)
+	}
+}
+
+func (p *skylinePack) setObserver(observer Observer) {
+	p.algorithmBase.setObserver(observer)
+	if p.wasteMap != nil {
+		p.wasteMap.setObserver(observer)
+	}
+}
+
+func (p *skylinePack) AllowFlip(enabled bool) {
+	p.algorithmBase.AllowFlip(enabled)
+	if p.wasteMap != nil {
+		p.wasteMap.AllowFlip(enabled)
 	}
 }
 
 func (p *skylinePack) Insert(padding int, sizes ...Size) []Size {
-	for len(sizes) > 0 {
+	for len(sizes) > 0 && !p.canceled() {
+		// First try to pack a rectangle into the waste map, if one fits.
+		if index, ok := p.insertWaste(p.wasteMap, padding, sizes); ok {
+			sizes = slices.Delete(sizes, index, index+1)
+			continue
+		}
 
 		var bestNode Rect
 		bestScore1 := math.MaxInt
@@ -53,13 +79,17 @@ func (p *skylinePack) Insert(padding int, sizes ...Size) []Size {
 		for i, size := range sizes {
 			var score1, score2, index int
 			var newNode Rect
-			padSize(&size, padding)
+			p.padSize(&size, padding)
+			opts := p.options(size.Options)
+			if p.observer != nil {
+				p.scoring = size.ID
+			}
 
 			switch p.levelSelect {
 			case MinWaste:
-				newNode = p.findMinWaste(size.Width, size.Height, &score2, &score1, &index)
+				newNode = p.findMinWaste(size.Width, size.Height, opts, &score2, &score1, &index)
 			default: // LevelBottomLeft or invalid
-				newNode = p.findBottomLeft(size.Width, size.Height, &score1, &score2, &index)
+				newNode = p.findBottomLeft(size.Width, size.Height, opts, &score1, &score2, &index)
 			}
 
 			if newNode.Height != 0 {
@@ -77,13 +107,16 @@ func (p *skylinePack) Insert(padding int, sizes ...Size) []Size {
 			break
 		}
 
-		// Perform the actual packing.
-		p.addLevel(bestBinIndex, &bestNode)
+		// Perform the actual packing. The level is raised from the start of the node, which
+		// includes any space skipped to align the rectangle.
+		level := bestNode
+		level.X = p.skyline[bestBinIndex].X
+		level.Width += bestNode.X - level.X
+		p.addLevel(bestBinIndex, &level)
 		p.usedArea += bestNode.Area()
 
-		unpadRect(&bestNode, padding)
-		bestNode.ID = sizes[bestSizeIndex].ID
-		p.packed = append(p.packed, bestNode)
+		unpadRect(&bestNode, sizes[bestSizeIndex])
+		p.addPacked(bestNode)
 
 		sizes = slices.Delete(sizes, bestSizeIndex, bestSizeIndex+1)
 	}
@@ -91,6 +124,87 @@ func (p *skylinePack) Insert(padding int, sizes ...Size) []Size {
 	return sizes
 }
 
+func (p *skylinePack) Remove(padding, id int) bool {
+	rect, ok := p.remove(id)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(rect, padding)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+func (p *skylinePack) Place(padding int, rects ...Rect) []Rect {
+	failed := p.place(padding, rects, nil)
+	p.rebuild(padding)
+	return failed
+}
+
+// Reserve marks areas of the bin as occupied. As the skyline cannot represent space beneath
+// a reserved rectangle, it is only available to the waste map when one is used.
+func (p *skylinePack) Reserve(padding int, rects ...Rect) []Rect {
+	failed := p.reserve(padding, rects, nil)
+	p.rebuild(padding)
+	return failed
+}
+
+func (p *skylinePack) Unreserve(padding int, rect Rect) bool {
+	reserved, ok := p.unreserve(rect)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(reserved, 0)
+	p.usedArea -= cell.Area()
+	p.rebuild(padding)
+	return true
+}
+
+// rebuild recomputes the skyline from the packed rectangles, where the level of each segment is
+// the bottom-most edge of the rectangles that cover it. When a waste map is used, it is
+// replaced with the free areas that remain below the skyline.
+func (p *skylinePack) rebuild(padding int) {
+	rects := p.occupied(padding)
+	edges := []int{0, p.maxWidth}
+	for _, rect := range rects {
+		edges = append(edges, rect.X, rect.Right())
+	}
+	slices.Sort(edges)
+	edges = slices.Compact(edges)
+
+	levels := make([]int, len(edges)-1)
+	for _, rect := range rects {
+		i, _ := slices.BinarySearch(edges, rect.X)
+		for ; i < len(levels) && edges[i] < rect.Right(); i++ {
+			levels[i] = max(levels[i], rect.Bottom())
+		}
+	}
+
+	p.skyline = p.skyline[:0]
+	for i, level := range levels {
+		p.skyline = append(p.skyline, skylineNode{X: edges[i], Y: level, Width: edges[i+1] - edges[i]})
+	}
+	p.mergeSkylines()
+
+	if p.wasteMap == nil {
+		return
+	}
+
+	waste := p.wasteMap.freeRects[:0]
+	for _, node := range p.skyline {
+		if node.Y > 0 {
+			waste = append(waste, NewRect(node.X, 0, node.Width, node.Y))
+		}
+	}
+	for _, rect := range rects {
+		waste = subtractRect(waste, NewRect(rect.X, rect.Y, rect.Width, rect.Height))
+	}
+	p.wasteMap.freeRects = waste
+	p.wasteMap.mergeFreeList()
+}
+
 func (p *skylinePack) Used() float64 {
 	return float64(p.usedArea) / float64(p.maxWidth*p.maxHeight)
 }
@@ -98,6 +212,9 @@ func (p *skylinePack) Used() float64 {
 func (p *skylinePack) mergeSkylines() {
 	for i := 0; i < len(p.skyline)-1; i++ {
 		if p.skyline[i].Y == p.skyline[i+1].Y {
+			if p.observer != nil {
+				p.observeMerge(p.nodeArea(i), p.nodeArea(i+1))
+			}
 			p.skyline[i].Width += p.skyline[i+1].Width
 			p.skyline = slices.Delete(p.skyline, i+1, i+2)
 			i--
@@ -105,13 +222,24 @@ func (p *skylinePack) mergeSkylines() {
 	}
 }
 
-func (p *skylinePack) testFit(index, width, height int, y *int) bool {
-	x := p.skyline[index].X
-	if x+width > p.maxWidth {
+// nodeArea returns the area above the skyline node at the specified index, which is how a node is
+// described to an observer.
+func (p *skylinePack) nodeArea(index int) Rect {
+	node := p.skyline[index]
+	return NewRect(node.X, node.Y, node.Width, p.maxHeight-node.Y)
+}
+
+// testFit tests whether a rectangle fits onto the skyline starting at the node with the specified
+// index, computing the location of the rectangle when aligned. Any space skipped to align the
+// rectangle horizontally is considered to be occupied by it.
+func (p *skylinePack) testFit(index, width, height int, opts SizeOptions, x, y *int) bool {
+	start := p.skyline[index].X
+	*x = alignUp(start, opts.AlignX)
+	if *x+width > p.maxWidth {
 		return false
 	}
 
-	widthLeft := width
+	widthLeft := *x + width - start
 	i := index
 	*y = p.skyline[index].Y
 	for widthLeft > 0 {
@@ -122,13 +250,15 @@ func (p *skylinePack) testFit(index, width, height int, y *int) bool {
 		widthLeft -= p.skyline[i].Width
 		i++
 	}
-	return true
+
+	*y = alignUp(*y, opts.AlignY)
+	return *y+height <= p.maxHeight
 }
 
-func (p *skylinePack) testFitWithWaste(index, width, height int, y, wastedArea *int) bool {
-	fits := p.testFit(index, width, height, y)
+func (p *skylinePack) testFitWithWaste(index, width, height int, opts SizeOptions, x, y, wastedArea *int) bool {
+	fits := p.testFit(index, width, height, opts, x, y)
 	if fits {
-		*wastedArea = p.computeWaste(index, width, height, *y)
+		*wastedArea = p.computeWaste(index, *x+width-p.skyline[index].X, height, *y)
 	}
 	return fits
 }
@@ -208,32 +338,40 @@ func (p *skylinePack) addLevel(index int, rect *Rect) {
 	p.mergeSkylines()
 }
 
-func (p *skylinePack) findBottomLeft(width, height int, bestHeight, bestWidth, bestIndex *int) Rect {
+func (p *skylinePack) findBottomLeft(width, height int, opts SizeOptions, bestHeight, bestWidth, bestIndex *int) Rect {
 	*bestHeight = math.MaxInt
 	*bestIndex = -1
 	// Used to break ties if there are nodes at the same level. Then pick the narrowest one.
 	*bestWidth = math.MaxInt
 
 	var newNode Rect
+	flip := p.canFlip(opts)
 	for i := 0; i < len(p.skyline); i++ {
-		var y int
-		if p.testFit(i, width, height, &y) {
+		var x, y int
+		if p.testFit(i, width, height, opts, &x, &y) {
+			if p.observer != nil {
+				p.observeScore(p.nodeArea(i), x, y, width, height, false, y+height, p.skyline[i].Width)
+			}
 			if y+height < *bestHeight || (y+height == *bestHeight && p.skyline[i].Width < *bestWidth) {
 				*bestHeight = y + height
 				*bestIndex = i
 				*bestWidth = p.skyline[i].Width
-				newNode.X = p.skyline[i].X
+				newNode.X = x
 				newNode.Y = y
 				newNode.Width = width
 				newNode.Height = height
+				newNode.Flipped = false
 			}
 		}
-		if p.allowFlip && p.testFit(i, height, width, &y) {
+		if flip && p.testFit(i, height, width, opts, &x, &y) {
+			if p.observer != nil {
+				p.observeScore(p.nodeArea(i), x, y, height, width, true, y+width, p.skyline[i].Width)
+			}
 			if y+width < *bestHeight || (y+width == *bestHeight && p.skyline[i].Width < *bestWidth) {
 				*bestHeight = y + width
 				*bestIndex = i
 				*bestWidth = p.skyline[i].Width
-				newNode.X = p.skyline[i].X
+				newNode.X = x
 				newNode.Y = y
 				newNode.Width = height
 				newNode.Height = width
@@ -245,34 +383,42 @@ func (p *skylinePack) findBottomLeft(width, height int, bestHeight, bestWidth, b
 	return newNode
 }
 
-func (p *skylinePack) findMinWaste(width, height int, bestHeight, bestWastedArea, bestIndex *int) Rect {
+func (p *skylinePack) findMinWaste(width, height int, opts SizeOptions, bestHeight, bestWastedArea, bestIndex *int) Rect {
 	*bestHeight = math.MaxInt
 	*bestWastedArea = math.MaxInt
 	*bestIndex = -1
 	var newNode Rect
+	flip := p.canFlip(opts)
 
 	for i := 0; i < len(p.skyline); i++ {
-		var y int
+		var x, y int
 		var wasted int
 
-		if p.testFitWithWaste(i, width, height, &y, &wasted) {
+		if p.testFitWithWaste(i, width, height, opts, &x, &y, &wasted) {
+			if p.observer != nil {
+				p.observeScore(p.nodeArea(i), x, y, width, height, false, wasted, y+height)
+			}
 			if wasted < *bestWastedArea || (wasted == *bestWastedArea && y+height < *bestHeight) {
 				*bestHeight = y + height
 				*bestWastedArea = wasted
 				*bestIndex = i
-				newNode.X = p.skyline[i].X
+				newNode.X = x
 				newNode.Y = y
 				newNode.Width = width
 				newNode.Height = height
+				newNode.Flipped = false
 			}
 		}
 
-		if p.allowFlip && p.testFitWithWaste(i, height, width, &y, &wasted) {
+		if flip && p.testFitWithWaste(i, height, width, opts, &x, &y, &wasted) {
+			if p.observer != nil {
+				p.observeScore(p.nodeArea(i), x, y, height, width, true, wasted, y+width)
+			}
 			if wasted < *bestWastedArea || (wasted == *bestWastedArea && y+width < *bestHeight) {
 				*bestHeight = y + width
 				*bestWastedArea = wasted
 				*bestIndex = i
-				newNode.X = p.skyline[i].X
+				newNode.X = x
 				newNode.Y = y
 				newNode.Width = height
 				newNode.Height = width
@@ -0,0 +1,704 @@
+package rectpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// snapshotMagic identifies the binary snapshot format.
+const snapshotMagic = "RPK"
+
+// snapshotVersion is the version of the binary snapshot format, which must be incremented
+// whenever its layout changes.
+const snapshotVersion = 1
+
+var (
+	errSnapshotAlgo = errors.New("algorithm does not support snapshots")
+	errSnapshotSort = errors.New("custom sort functions cannot be saved in a snapshot")
+	errSnapshotData = errors.New("invalid snapshot data")
+)
+
+// snapshotter is implemented by algorithms whose internal state can be saved and restored, such
+// that future placements are identical to those of the original.
+type snapshotter interface {
+	// saveState writes the internal state of the algorithm to the snapshot.
+	saveState(state *packerState)
+	// loadState restores the internal state of the algorithm from the snapshot. The algorithm
+	// has already been created with the heuristic, maximum size, flip setting, and block size it
+	// contains.
+	loadState(state *packerState) error
+}
+
+// rectState is the serialized form of a rectangle, which unlike Rect includes its ID and options.
+type rectState struct {
+	Rect
+	ID      int          `json:"id,omitempty"`
+	Options *SizeOptions `json:"options,omitempty"`
+}
+
+// sizeState is the serialized form of a size, which unlike Size includes its ID and options.
+type sizeState struct {
+	Size
+	ID      int          `json:"id,omitempty"`
+	Options *SizeOptions `json:"options,omitempty"`
+}
+
+// saveOptions converts options to their serialized form, which is omitted when they are unset.
+func saveOptions(opts SizeOptions) *SizeOptions {
+	if opts == (SizeOptions{}) {
+		return nil
+	}
+	return &opts
+}
+
+// loadOptions converts options from their serialized form.
+func loadOptions(opts *SizeOptions) SizeOptions {
+	if opts == nil {
+		return SizeOptions{}
+	}
+	return *opts
+}
+
+// treeState is the serialized form of a node of a binary tree, which is stored in preorder with
+// the number of children it has.
+type treeState struct {
+	X        int  `json:"x"`
+	Y        int  `json:"y"`
+	Width    int  `json:"width"`
+	Height   int  `json:"height"`
+	Children int  `json:"children,omitempty"`
+	Occupied bool `json:"occupied,omitempty"`
+}
+
+// packerState is the serialized form of a Packer, including the internal state of its algorithm.
+type packerState struct {
+	Heuristic Heuristic     `json:"heuristic"`
+	Width     int           `json:"width"`
+	Height    int           `json:"height"`
+	Padding   int           `json:"padding,omitempty"`
+	AllowFlip bool          `json:"allowFlip,omitempty"`
+	Block     int           `json:"block,omitempty"`
+	Online    bool          `json:"online,omitempty"`
+	Sort      string        `json:"sort"`
+	Reverse   bool          `json:"reverse,omitempty"`
+	Packed    []rectState   `json:"packed"`
+	Reserved  []rectState   `json:"reserved,omitempty"`
+	Unpacked  []sizeState   `json:"unpacked,omitempty"`
+	Aliases   map[int]int   `json:"aliases,omitempty"`
+	FreeRects []rectState   `json:"freeRects,omitempty"`
+	Skyline   []skylineNode `json:"skyline,omitempty"`
+	Shelves   []shelf       `json:"shelves,omitempty"`
+	Waste     []rectState   `json:"waste,omitempty"`
+	Tree      []treeState   `json:"tree,omitempty"`
+}
+
+// saveRects converts rectangles to their serialized form.
+func saveRects(rects []Rect) []rectState {
+	states := make([]rectState, len(rects))
+	for i, rect := range rects {
+		states[i] = rectState{Rect: rect, ID: rect.ID, Options: saveOptions(rect.Options)}
+	}
+	return states
+}
+
+// loadRects converts rectangles from their serialized form.
+func loadRects(states []rectState) []Rect {
+	rects := make([]Rect, len(states))
+	for i, state := range states {
+		rects[i] = state.Rect
+		rects[i].ID = state.ID
+		rects[i].Options = loadOptions(state.Options)
+	}
+	return rects
+}
+
+// saveBase writes the state common to all algorithms.
+func (p *algorithmBase) saveBase(state *packerState) {
+	state.Width = p.maxWidth
+	state.Height = p.maxHeight
+	state.AllowFlip = p.allowFlip
+	state.Block = p.block
+	state.Packed = saveRects(p.packed)
+	state.Reserved = saveRects(p.reserved)
+}
+
+// loadBase restores the state common to all algorithms, ensuring that the rectangles are within
+// the maximum extents. The used area is computed from the cells of the rectangles.
+func (p *algorithmBase) loadBase(state *packerState) error {
+	p.packed = loadRects(state.Packed)
+	p.reserved = loadRects(state.Reserved)
+	if !p.contains(p.packed) || !p.contains(p.reserved) {
+		return errSnapshotData
+	}
+
+	p.usedArea = 0
+	for _, cell := range p.occupied(state.Padding) {
+		p.usedArea += cell.Area()
+	}
+	return nil
+}
+
+// contains tests whether all rectangles are within the maximum extents.
+func (p *algorithmBase) contains(rects []Rect) bool {
+	for _, rect := range rects {
+		if rect.X < 0 || rect.Y < 0 || rect.Width < 0 || rect.Height < 0 ||
+			rect.Right() > p.maxWidth || rect.Bottom() > p.maxHeight {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *maxRects) saveState(state *packerState) {
+	p.saveBase(state)
+	state.FreeRects = saveRects(p.freeRects)
+}
+
+func (p *maxRects) loadState(state *packerState) error {
+	p.freeRects = loadRects(state.FreeRects)
+	if !p.contains(p.freeRects) {
+		return errSnapshotData
+	}
+	return p.loadBase(state)
+}
+
+func (p *guillotinePack) saveState(state *packerState) {
+	p.saveBase(state)
+	state.FreeRects = saveRects(p.freeRects)
+}
+
+func (p *guillotinePack) loadState(state *packerState) error {
+	p.freeRects = loadRects(state.FreeRects)
+	if !p.contains(p.freeRects) {
+		return errSnapshotData
+	}
+	return p.loadBase(state)
+}
+
+func (p *skylinePack) saveState(state *packerState) {
+	p.saveBase(state)
+	state.Skyline = append([]skylineNode(nil), p.skyline...)
+	if p.wasteMap != nil {
+		state.Waste = saveRects(p.wasteMap.freeRects)
+	}
+}
+
+func (p *skylinePack) loadState(state *packerState) error {
+	// The nodes must be sorted and without gaps, spanning the entire width.
+	x := 0
+	for _, node := range state.Skyline {
+		if node.X != x || node.Width <= 0 || node.Y < 0 || node.Y > p.maxHeight {
+			return errSnapshotData
+		}
+		x += node.Width
+	}
+	if x != p.maxWidth {
+		return errSnapshotData
+	}
+	p.skyline = append(p.skyline[:0], state.Skyline...)
+
+	if p.wasteMap != nil {
+		p.wasteMap.freeRects = loadRects(state.Waste)
+		if !p.contains(p.wasteMap.freeRects) {
+			return errSnapshotData
+		}
+	}
+	return p.loadBase(state)
+}
+
+func (p *shelfPack) saveState(state *packerState) {
+	p.saveBase(state)
+	state.Shelves = append([]shelf(nil), p.shelves...)
+	if p.wasteMap != nil {
+		state.Waste = saveRects(p.wasteMap.freeRects)
+	}
+}
+
+func (p *shelfPack) loadState(state *packerState) error {
+	// The shelves must be sorted from top to bottom without overlapping.
+	bottom := 0
+	for _, shelf := range state.Shelves {
+		if shelf.StartY < bottom || shelf.Height < 0 || shelf.CurrentX < 0 || shelf.CurrentX > p.maxWidth ||
+			shelf.StartY+shelf.Height > p.maxHeight {
+			return errSnapshotData
+		}
+		bottom = shelf.StartY + shelf.Height
+	}
+	p.shelves = append(p.shelves[:0], state.Shelves...)
+
+	if p.wasteMap != nil {
+		p.wasteMap.freeRects = loadRects(state.Waste)
+		if !p.contains(p.wasteMap.freeRects) {
+			return errSnapshotData
+		}
+	}
+	return p.loadBase(state)
+}
+
+func (p *treePack) saveState(state *packerState) {
+	p.saveBase(state)
+	var save func(node *treeNode)
+	save = func(node *treeNode) {
+		state.Tree = append(state.Tree, treeState{
+			X:        node.X,
+			Y:        node.Y,
+			Width:    node.Width,
+			Height:   node.Height,
+			Children: len(node.children),
+			Occupied: node.occupied,
+		})
+		for _, child := range node.children {
+			save(child)
+		}
+	}
+	save(p.root)
+}
+
+func (p *treePack) loadState(state *packerState) error {
+	nodes := state.Tree
+	var load func() *treeNode
+	load = func() *treeNode {
+		if len(nodes) == 0 {
+			return nil
+		}
+		node := &treeNode{Rect: NewRect(nodes[0].X, nodes[0].Y, nodes[0].Width, nodes[0].Height)}
+		node.occupied = nodes[0].Occupied
+		children := nodes[0].Children
+		nodes = nodes[1:]
+		if !p.contains([]Rect{node.Rect}) || children < 0 || children > len(nodes) || (node.occupied && children > 0) {
+			return nil
+		}
+		for i := 0; i < children; i++ {
+			child := load()
+			if child == nil {
+				return nil
+			}
+			node.children = append(node.children, child)
+		}
+		if !partitioned(node) {
+			return nil
+		}
+		return node
+	}
+
+	// The root covers the entire area, which is empty or grows from the top-left corner when
+	// growing.
+	root := load()
+	if root == nil || len(nodes) != 0 || root.X != 0 || root.Y != 0 {
+		return errSnapshotData
+	}
+	if !p.grow && (root.Width != p.maxWidth || root.Height != p.maxHeight) {
+		return errSnapshotData
+	}
+	p.root = root
+	return p.loadBase(state)
+}
+
+// partitioned tests whether the children of a node are disjoint and together cover exactly its
+// area, which is always true of a leaf.
+func partitioned(node *treeNode) bool {
+	if len(node.children) == 0 {
+		return true
+	}
+
+	area := 0
+	for i, child := range node.children {
+		if child.IsEmpty() || !node.ContainsRect(child.Rect) {
+			return false
+		}
+		for _, other := range node.children[i+1:] {
+			if child.Intersects(other.Rect) {
+				return false
+			}
+		}
+		area += child.Area()
+	}
+	return area == node.Area()
+}
+
+// state returns a snapshot of the packer.
+func (p *Packer) state() (*packerState, error) {
+	algo, ok := p.algo.(snapshotter)
+	if !ok {
+		return nil, errSnapshotAlgo
+	}
+	sort, ok := sortFuncName(p.sortFunc)
+	if !ok {
+		return nil, errSnapshotSort
+	}
+
+	state := &packerState{
+		Heuristic: p.heuristic,
+		Padding:   p.Padding,
+		Online:    p.Online,
+		Sort:      sort,
+		Reverse:   p.sortRev,
+		Unpacked:  make([]sizeState, len(p.unpacked)),
+		Aliases:   maps.Clone(p.aliases),
+	}
+	for i, size := range p.unpacked {
+		state.Unpacked[i] = sizeState{Size: size, ID: size.ID, Options: saveOptions(size.Options)}
+	}
+
+	algo.saveState(state)
+	return state, nil
+}
+
+// restore replaces the packer with the contents of a snapshot. The receiver is not modified when
+// an error is returned.
+func (p *Packer) restore(state *packerState) error {
+	compare, err := ParseSortFunc(state.Sort)
+	if err != nil {
+		return err
+	}
+	algo, err := NewAlgorithm(state.Width, state.Height, state.Heuristic)
+	if err != nil {
+		return err
+	}
+
+	loader, ok := algo.(snapshotter)
+	if !ok {
+		return errSnapshotAlgo
+	}
+	algo.AllowFlip(state.AllowFlip)
+	if block, ok := algo.(blockAligner); ok {
+		block.setBlock(state.Block)
+	}
+	if err := loader.loadState(state); err != nil {
+		return err
+	}
+
+	unpacked := make([]Size, len(state.Unpacked))
+	for i, size := range state.Unpacked {
+		unpacked[i] = size.Size
+		unpacked[i].ID = size.ID
+		unpacked[i].Options = loadOptions(size.Options)
+	}
+
+	*p = Packer{
+		unpacked:  unpacked,
+		algo:      algo,
+		heuristic: state.Heuristic,
+		aliases:   state.Aliases,
+		sortFunc:  compare,
+		Padding:   state.Padding,
+		sortRev:   state.Reverse,
+		Online:    state.Online,
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface, encoding the complete state of the
+// packer, including its configuration, staged sizes, and the internal state of its algorithm.
+// A packer restored from the result produces identical placements to the original.
+//
+// Returns an error if a custom sort function is in use, as functions cannot be encoded.
+func (p *Packer) MarshalJSON() ([]byte, error) {
+	state, err := p.state()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, restoring the complete state of a
+// packer encoded with MarshalJSON.
+func (p *Packer) UnmarshalJSON(data []byte) error {
+	var state packerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	return p.restore(&state)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, encoding the same state as
+// MarshalJSON in a compact binary form.
+func (p *Packer) MarshalBinary() ([]byte, error) {
+	state, err := p.state()
+	if err != nil {
+		return nil, err
+	}
+
+	w := stateWriter{buf: append([]byte(snapshotMagic), snapshotVersion)}
+	w.uint(uint64(state.Heuristic))
+	w.int(state.Width)
+	w.int(state.Height)
+	w.int(state.Padding)
+	w.bool(state.AllowFlip)
+	w.int(state.Block)
+	w.bool(state.Online)
+	w.string(state.Sort)
+	w.bool(state.Reverse)
+	w.rects(state.Packed)
+	w.rects(state.Reserved)
+
+	w.int(len(state.Unpacked))
+	for _, size := range state.Unpacked {
+		w.int(size.ID)
+		w.int(size.Width)
+		w.int(size.Height)
+		w.options(size.Options)
+	}
+
+	// Aliases are written in order of their ID so that the output is deterministic.
+	aliases := make([]int, 0, len(state.Aliases))
+	for id := range state.Aliases {
+		aliases = append(aliases, id)
+	}
+	slices.Sort(aliases)
+	w.int(len(aliases))
+	for _, id := range aliases {
+		w.int(id)
+		w.int(state.Aliases[id])
+	}
+
+	w.rects(state.FreeRects)
+	w.int(len(state.Skyline))
+	for _, node := range state.Skyline {
+		w.int(node.X)
+		w.int(node.Y)
+		w.int(node.Width)
+	}
+	w.int(len(state.Shelves))
+	for _, shelf := range state.Shelves {
+		w.int(shelf.StartY)
+		w.int(shelf.Height)
+		w.int(shelf.CurrentX)
+	}
+	w.rects(state.Waste)
+	w.int(len(state.Tree))
+	for _, node := range state.Tree {
+		w.int(node.X)
+		w.int(node.Y)
+		w.int(node.Width)
+		w.int(node.Height)
+		w.int(node.Children)
+		w.bool(node.Occupied)
+	}
+	return w.buf, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface, restoring the complete
+// state of a packer encoded with MarshalBinary.
+func (p *Packer) UnmarshalBinary(data []byte) error {
+	header := len(snapshotMagic) + 1
+	if len(data) < header || string(data[:len(snapshotMagic)]) != snapshotMagic {
+		return errSnapshotData
+	}
+	if version := data[header-1]; version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+
+	r := stateReader{buf: data[header:]}
+	var state packerState
+	state.Heuristic = Heuristic(r.uint())
+	state.Width = r.int()
+	state.Height = r.int()
+	state.Padding = r.int()
+	state.AllowFlip = r.bool()
+	state.Block = r.int()
+	state.Online = r.bool()
+	state.Sort = r.string()
+	state.Reverse = r.bool()
+	state.Packed = r.rects()
+	state.Reserved = r.rects()
+
+	if count := r.count(7); count > 0 {
+		state.Unpacked = make([]sizeState, count)
+		for i := range state.Unpacked {
+			state.Unpacked[i].ID = r.int()
+			state.Unpacked[i].Width = r.int()
+			state.Unpacked[i].Height = r.int()
+			state.Unpacked[i].Options = r.options()
+		}
+	}
+
+	if count := r.count(2); count > 0 {
+		state.Aliases = make(map[int]int, count)
+		for i := 0; i < count; i++ {
+			id := r.int()
+			state.Aliases[id] = r.int()
+		}
+	}
+
+	state.FreeRects = r.rects()
+	if count := r.count(3); count > 0 {
+		state.Skyline = make([]skylineNode, count)
+		for i := range state.Skyline {
+			state.Skyline[i] = skylineNode{X: r.int(), Y: r.int(), Width: r.int()}
+		}
+	}
+	if count := r.count(3); count > 0 {
+		state.Shelves = make([]shelf, count)
+		for i := range state.Shelves {
+			state.Shelves[i] = shelf{StartY: r.int(), Height: r.int(), CurrentX: r.int()}
+		}
+	}
+	state.Waste = r.rects()
+	if count := r.count(6); count > 0 {
+		state.Tree = make([]treeState, count)
+		for i := range state.Tree {
+			state.Tree[i] = treeState{X: r.int(), Y: r.int(), Width: r.int(), Height: r.int(), Children: r.int(), Occupied: r.bool()}
+		}
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.buf) != 0 {
+		return errSnapshotData
+	}
+	return p.restore(&state)
+}
+
+// stateWriter appends values to a buffer in the binary snapshot format.
+type stateWriter struct {
+	buf []byte
+}
+
+func (w *stateWriter) uint(value uint64) {
+	w.buf = binary.AppendUvarint(w.buf, value)
+}
+
+func (w *stateWriter) int(value int) {
+	w.buf = binary.AppendVarint(w.buf, int64(value))
+}
+
+func (w *stateWriter) bool(value bool) {
+	if value {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *stateWriter) string(value string) {
+	w.int(len(value))
+	w.buf = append(w.buf, value...)
+}
+
+func (w *stateWriter) rects(rects []rectState) {
+	w.int(len(rects))
+	for _, rect := range rects {
+		w.int(rect.ID)
+		w.int(rect.X)
+		w.int(rect.Y)
+		w.int(rect.Width)
+		w.int(rect.Height)
+		w.int(rect.Bin)
+		w.bool(rect.Flipped)
+		w.options(rect.Options)
+	}
+}
+
+func (w *stateWriter) options(opts *SizeOptions) {
+	value := loadOptions(opts)
+	w.bool(value.NoFlip)
+	w.int(value.Padding)
+	w.int(value.AlignX)
+	w.int(value.AlignY)
+}
+
+// stateReader reads values from a buffer in the binary snapshot format. After the first error,
+// all reads return zero values and the error is retained.
+type stateReader struct {
+	buf []byte
+	err error
+}
+
+func (r *stateReader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errSnapshotData
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return value
+}
+
+func (r *stateReader) int() int {
+	if r.err != nil {
+		return 0
+	}
+	value, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = errSnapshotData
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(value)
+}
+
+func (r *stateReader) bool() bool {
+	if r.err != nil {
+		return false
+	}
+	if len(r.buf) == 0 || r.buf[0] > 1 {
+		r.err = errSnapshotData
+		return false
+	}
+	value := r.buf[0] == 1
+	r.buf = r.buf[1:]
+	return value
+}
+
+// count reads the length of a sequence whose elements are each at least the specified number
+// of bytes, ensuring the buffer is large enough to contain it.
+func (r *stateReader) count(size int) int {
+	count := r.int()
+	if count < 0 || count > len(r.buf)/size {
+		if r.err == nil {
+			r.err = errSnapshotData
+		}
+		return 0
+	}
+	return count
+}
+
+func (r *stateReader) string() string {
+	count := r.count(1)
+	if r.err != nil {
+		return ""
+	}
+	value := string(r.buf[:count])
+	r.buf = r.buf[count:]
+	return value
+}
+
+func (r *stateReader) rects() []rectState {
+	count := r.count(11)
+	if count == 0 {
+		return nil
+	}
+
+	rects := make([]rectState, count)
+	for i := range rects {
+		rects[i].ID = r.int()
+		rects[i].X = r.int()
+		rects[i].Y = r.int()
+		rects[i].Width = r.int()
+		rects[i].Height = r.int()
+		rects[i].Bin = r.int()
+		rects[i].Flipped = r.bool()
+		rects[i].Options = r.options()
+	}
+	return rects
+}
+
+func (r *stateReader) options() *SizeOptions {
+	var opts SizeOptions
+	opts.NoFlip = r.bool()
+	opts.Padding = r.int()
+	opts.AlignX = r.int()
+	opts.AlignY = r.int()
+	return saveOptions(opts)
+}
+
+// vim: ts=4
@@ -1,11 +1,62 @@
 package rectpack
 
-import "cmp"
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
 
 // SortFunc is a prototype for a funcion that compares two rectangle sizes, returning standard
 // comparer result of -1 for less-than, 1 for greater-than, or 0 for equal to.
 type SortFunc func(a, b Size) int
 
+// sortFuncs contains each of the built-in comparers, along with a short name describing it.
+var sortFuncs = []struct {
+	name    string
+	compare SortFunc
+}{
+	{"area", SortArea},
+	{"perimeter", SortPerimeter},
+	{"diff", SortDiff},
+	{"minside", SortMinSide},
+	{"maxside", SortMaxSide},
+	{"ratio", SortRatio},
+}
+
+// ParseSortFunc returns the built-in comparer with the specified name, which is one of "area",
+// "perimeter", "diff", "minside", "maxside", or "ratio". The comparison is case-insensitive.
+//
+// The name "none" returns a nil function, which indicates sizes are not sorted.
+func ParseSortFunc(name string) (SortFunc, error) {
+	if strings.EqualFold(name, "none") {
+		return nil, nil
+	}
+	for _, sorter := range sortFuncs {
+		if strings.EqualFold(name, sorter.name) {
+			return sorter.compare, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown sort function %q", name)
+}
+
+// sortFuncName returns the name of a built-in comparer, which can be passed to ParseSortFunc to
+// retrieve it again. Returns false if the function is not built-in.
+func sortFuncName(compare SortFunc) (string, bool) {
+	if compare == nil {
+		return "none", true
+	}
+
+	ptr := reflect.ValueOf(compare).Pointer()
+	for _, sorter := range sortFuncs {
+		if reflect.ValueOf(sorter.compare).Pointer() == ptr {
+			return sorter.name, true
+		}
+	}
+	return "", false
+}
+
 // SortArea sorts two rectangle sizes in descending order (greatest to least) by comparing the
 // total area of each.
 func SortArea(a, b Size) int {
@@ -42,4 +93,20 @@ func SortRatio(a, b Size) int {
 	return cmp.Compare(b.Ratio(), a.Ratio())
 }
 
+// sortSizes sorts the sizes in-place using the specified comparer, optionally reversing the
+// order. When compare is nil, the sizes are left in their current order unless reverse is set.
+func sortSizes(sizes []Size, compare SortFunc, reverse bool) {
+	if compare != nil {
+		if reverse {
+			slices.SortFunc(sizes, func(a, b Size) int {
+				return compare(b, a)
+			})
+		} else {
+			slices.SortFunc(sizes, compare)
+		}
+	} else if reverse {
+		slices.Reverse(sizes)
+	}
+}
+
 // vim: ts=4
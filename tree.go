@@ -0,0 +1,326 @@
+package rectpack
+
+// treeNode is a region of the bin. Leaves are either free or occupied, while the children of
+// other nodes partition its region without overlapping.
+type treeNode struct {
+	Rect
+	children []*treeNode
+	occupied bool
+}
+
+type treePack struct {
+	algorithmBase
+	grow bool
+	root *treeNode
+}
+
+func newTree(width, height int, heuristic Heuristic) *treePack {
+	var packer treePack
+	packer.grow = heuristic&Grow != 0
+	packer.Reset(width, height)
+	return &packer
+}
+
+func (p *treePack) Reset(width, height int) {
+	p.algorithmBase.Reset(width, height)
+	if p.grow {
+		// The tree is empty until the first rectangle determines its initial size.
+		p.root = &treeNode{}
+	} else {
+		p.root = &treeNode{Rect: NewRect(0, 0, p.maxWidth, p.maxHeight)}
+	}
+
+	for _, cell := range p.reservedCells() {
+		p.occupy(cell)
+	}
+}
+
+// Insert packs the sizes in the order they are given, placing each into the first free node in
+// which it fits.
+func (p *treePack) Insert(padding int, sizes ...Size) []Size {
+	failed := sizes[:0]
+	for i, size := range sizes {
+		if p.canceled() {
+			return append(failed, sizes[i:]...)
+		}
+		if !p.insert(padding, size) {
+			failed = append(failed, size)
+		}
+	}
+	return failed
+}
+
+func (p *treePack) insert(padding int, size Size) bool {
+	padded := size
+	p.padSize(&padded, padding)
+	width, height, opts := padded.Width, padded.Height, p.options(size.Options)
+	if p.observer != nil {
+		p.scoring = size.ID
+	}
+
+	node, flipped := p.findNode(p.root, width, height, opts)
+	if node == nil {
+		if !p.grow || !p.growTree(width, height, opts) {
+			return false
+		}
+		if node, flipped = p.findNode(p.root, width, height, opts); node == nil {
+			return false
+		}
+	}
+
+	if flipped {
+		width, height = height, width
+	}
+	cell := alignFree(node.Rect, opts)
+	cell.Width, cell.Height = width, height
+	p.splitNode(node, cell)
+	p.usedArea += cell.Area()
+
+	cell.Flipped = flipped
+	unpadRect(&cell, size)
+	p.addPacked(cell)
+	return true
+}
+
+// findNode returns the first free leaf in which the size fits when aligned, searching to the
+// right before searching down, and whether the size must be flipped to fit.
+func (p *treePack) findNode(node *treeNode, width, height int, opts SizeOptions) (*treeNode, bool) {
+	if len(node.children) > 0 {
+		for _, child := range node.children {
+			if found, flipped := p.findNode(child, width, height, opts); found != nil {
+				return found, flipped
+			}
+		}
+		return nil, false
+	}
+
+	free := alignFree(node.Rect, opts)
+	switch {
+	case node.occupied:
+	case width <= free.Width && height <= free.Height:
+		if p.observer != nil {
+			p.observeScore(node.Rect, free.X, free.Y, width, height, false, 0, 0)
+		}
+		return node, false
+	case p.canFlip(opts) && height <= free.Width && width <= free.Height:
+		if p.observer != nil {
+			p.observeScore(node.Rect, free.X, free.Y, height, width, true, 0, 0)
+		}
+		return node, true
+	}
+	return nil, false
+}
+
+// splitNode divides a free leaf into the occupied cell at its top-left corner, the area to the
+// right of the cell, and the area below it. When the cell was moved from the corner for
+// alignment, the free areas around it are retained instead.
+func (p *treePack) splitNode(node *treeNode, cell Rect) {
+	if cell.Point != node.Point {
+		p.occupyNode(node, cell)
+		return
+	}
+
+	children := []*treeNode{{Rect: cell, occupied: true}}
+	right := NewRect(cell.Right(), node.Y, node.Right()-cell.Right(), cell.Height)
+	down := NewRect(node.X, cell.Bottom(), node.Width, node.Bottom()-cell.Bottom())
+	for _, rect := range []Rect{right, down} {
+		if !rect.IsEmpty() {
+			children = append(children, &treeNode{Rect: rect})
+		}
+	}
+	node.children = children
+	if p.observer != nil {
+		p.observeLeaf(node)
+	}
+}
+
+// observeLeaf notifies the observer that a free leaf was split into an occupied child and the
+// free children around it.
+func (p *treePack) observeLeaf(node *treeNode) {
+	parts := make([]Rect, 0, len(node.children)-1)
+	for _, child := range node.children[1:] {
+		parts = append(parts, child.Rect)
+	}
+	p.observeSplit(node.Rect, node.children[0].Rect, parts)
+}
+
+// growTree extends the tree to the right or down so that a size will fit, choosing whichever
+// keeps the tree closest to a square. Returns false if the tree cannot grow within the maximum
+// extents.
+func (p *treePack) growTree(width, height int, opts SizeOptions) bool {
+	root := p.root.Rect
+	if root.IsEmpty() {
+		if p.canFlip(opts) && (width > p.maxWidth || height > p.maxHeight) {
+			width, height = height, width
+		}
+		if width > p.maxWidth || height > p.maxHeight {
+			return false
+		}
+		p.root = &treeNode{Rect: NewRect(0, 0, width, height)}
+		return true
+	}
+
+	sizes := []Size{NewSize(width, height)}
+	if p.canFlip(opts) && width != height {
+		sizes = append(sizes, NewSize(height, width))
+	}
+	for i := range sizes {
+		// The new area may begin at an unaligned location, so allow for the worst case.
+		sizes[i].Width += max(0, opts.AlignX-1)
+		sizes[i].Height += max(0, opts.AlignY-1)
+	}
+
+	var best Size
+	bestSide, bestArea := 0, 0
+	for _, size := range sizes {
+		candidates := []Size{
+			NewSize(root.Width+size.Width, max(root.Height, size.Height)),
+			NewSize(max(root.Width, size.Width), root.Height+size.Height),
+		}
+		for _, candidate := range candidates {
+			if candidate.Width > p.maxWidth || candidate.Height > p.maxHeight {
+				continue
+			}
+			side, area := max(candidate.Width, candidate.Height), candidate.Area()
+			if bestSide == 0 || side < bestSide || (side == bestSide && area < bestArea) {
+				best, bestSide, bestArea = candidate, side, area
+			}
+		}
+	}
+
+	if bestSide == 0 {
+		return false
+	}
+	p.extend(best.Width, best.Height)
+	return true
+}
+
+// extend grows the tree to at least the specified size, adding free nodes for the new area to the
+// right of and below the current tree.
+func (p *treePack) extend(width, height int) {
+	root := p.root.Rect
+	width, height = max(width, root.Width), max(height, root.Height)
+	if width == root.Width && height == root.Height {
+		return
+	}
+	if root.IsEmpty() {
+		p.root = &treeNode{Rect: NewRect(0, 0, width, height)}
+		return
+	}
+
+	parent := &treeNode{Rect: NewRect(0, 0, width, height), children: []*treeNode{p.root}}
+	right := NewRect(root.Width, 0, width-root.Width, root.Height)
+	down := NewRect(0, root.Height, width, height-root.Height)
+	for _, rect := range []Rect{right, down} {
+		if !rect.IsEmpty() {
+			parent.children = append(parent.children, &treeNode{Rect: rect})
+		}
+	}
+	p.root = parent
+}
+
+// occupy marks an arbitrary area as occupied, splitting each free leaf it intersects into the
+// occupied intersection and the free areas around it.
+func (p *treePack) occupy(cell Rect) {
+	if p.grow {
+		p.extend(cell.Right(), cell.Bottom())
+	}
+	p.occupyNode(p.root, cell)
+}
+
+func (p *treePack) occupyNode(node *treeNode, cell Rect) {
+	if !node.Intersects(cell) {
+		return
+	}
+	if len(node.children) > 0 {
+		for _, child := range node.children {
+			p.occupyNode(child, cell)
+		}
+		return
+	}
+	if node.occupied {
+		return
+	}
+
+	used := node.Intersect(cell)
+	node.children = []*treeNode{{Rect: used, occupied: true}}
+	for _, rect := range subtractRect([]Rect{node.Rect}, used) {
+		node.children = append(node.children, &treeNode{Rect: rect})
+	}
+	if p.observer != nil {
+		p.observeLeaf(node)
+	}
+}
+
+// release frees the occupied leaves within an area, merging nodes whose children are all free.
+// Returns true if the node is a free leaf afterwards.
+func (p *treePack) release(node *treeNode, cell Rect) bool {
+	if !node.Intersects(cell) {
+		return !node.occupied && len(node.children) == 0
+	}
+	if len(node.children) == 0 {
+		node.occupied = false
+		return true
+	}
+
+	free := true
+	for _, child := range node.children {
+		if !p.release(child, cell) {
+			free = false
+		}
+	}
+	if free {
+		node.children = nil
+	}
+	return free
+}
+
+func (p *treePack) Remove(padding, id int) bool {
+	rect, ok := p.remove(id)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(rect, padding)
+	p.usedArea -= cell.Area()
+	p.release(p.root, cell)
+
+	// Padding may overlap reserved rectangles and other cells, so restore any that were freed.
+	for _, other := range p.occupied(padding) {
+		if other.Intersects(cell) {
+			p.occupyNode(p.root, other)
+		}
+	}
+	return true
+}
+
+func (p *treePack) Place(padding int, rects ...Rect) []Rect {
+	return p.place(padding, rects, p.occupy)
+}
+
+// Reserve marks areas of the bin as occupied. When the tree grows, it is immediately extended to
+// contain the reserved rectangles.
+func (p *treePack) Reserve(padding int, rects ...Rect) []Rect {
+	return p.reserve(padding, rects, p.occupy)
+}
+
+func (p *treePack) Unreserve(padding int, rect Rect) bool {
+	reserved, ok := p.unreserve(rect)
+	if !ok {
+		return false
+	}
+
+	cell := p.cell(reserved, 0)
+	p.usedArea -= cell.Area()
+	p.release(p.root, cell)
+
+	// Released leaves may also contain parts of other cells, so restore any that were freed.
+	for _, other := range p.occupied(padding) {
+		if other.Intersects(cell) {
+			p.occupyNode(p.root, other)
+		}
+	}
+	return true
+}
+
+// vim: ts=4
@@ -0,0 +1,291 @@
+package rectpack
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// ViolationKind describes an invariant of a layout that was not upheld.
+type ViolationKind uint8
+
+const (
+	// ViolationBounds indicates that a rectangle is empty or extends beyond the bin.
+	ViolationBounds ViolationKind = iota
+	// ViolationOverlap indicates that two rectangles overlap.
+	ViolationOverlap
+	// ViolationPadding indicates that two rectangles do not overlap, but are closer than their
+	// padding allows.
+	ViolationPadding
+	// ViolationReserved indicates that a rectangle or its padding overlaps a reserved area.
+	ViolationReserved
+	// ViolationFlipped indicates that the dimensions of a rectangle do not match its size, taking
+	// into account whether it is flipped, or that it is flipped when its size does not allow it.
+	ViolationFlipped
+	// ViolationDuplicate indicates that more than one rectangle has the same ID.
+	ViolationDuplicate
+	// ViolationUnknown indicates that the ID of a rectangle does not match any of the sizes.
+	ViolationUnknown
+	// ViolationMissing indicates that there is no rectangle with the ID of a size.
+	ViolationMissing
+)
+
+var violationNames = [...]string{
+	ViolationBounds:    "out of bounds",
+	ViolationOverlap:   "overlap",
+	ViolationPadding:   "padding",
+	ViolationReserved:  "reserved",
+	ViolationFlipped:   "flipped",
+	ViolationDuplicate: "duplicate",
+	ViolationUnknown:   "unknown",
+	ViolationMissing:   "missing",
+}
+
+// String returns a description of the kind of violation.
+func (e ViolationKind) String() string {
+	if int(e) < len(violationNames) {
+		return violationNames[e]
+	}
+	return fmt.Sprintf("ViolationKind(%d)", uint8(e))
+}
+
+// Violation describes a single problem found in a layout by Validate.
+type Violation struct {
+	// Kind is the invariant that was violated.
+	Kind ViolationKind
+	// Rect is the rectangle that violates the invariant. For missing IDs, it only has the ID and
+	// dimensions of the size that was not packed.
+	Rect Rect
+	// Other is the second rectangle involved in an overlap or padding violation, the reserved
+	// area that was overlapped, or the first rectangle with the same ID as a duplicate.
+	Other Rect
+	// Size is the size of a rectangle with the wrong dimensions.
+	Size Size
+}
+
+// String returns a description of the violation.
+func (v *Violation) String() string {
+	switch v.Kind {
+	case ViolationOverlap, ViolationPadding, ViolationDuplicate:
+		return fmt.Sprintf("%s: %d %s and %d %s", v.Kind.String(), v.Rect.ID, v.Rect.String(), v.Other.ID, v.Other.String())
+	case ViolationReserved:
+		return fmt.Sprintf("%s: %d %s and %s", v.Kind.String(), v.Rect.ID, v.Rect.String(), v.Other.String())
+	case ViolationFlipped:
+		return fmt.Sprintf("%s: %d %s does not match size %s", v.Kind.String(), v.Rect.ID, v.Rect.String(), v.Size.String())
+	case ViolationMissing:
+		return fmt.Sprintf("%s: %d %s", v.Kind.String(), v.Rect.ID, v.Rect.Size.String())
+	default:
+		return fmt.Sprintf("%s: %d %s", v.Kind.String(), v.Rect.ID, v.Rect.String())
+	}
+}
+
+// Layout describes the result of packing a single bin, which is verified by Validate.
+type Layout struct {
+	// Bounds is the size of the bin that contains the rectangles.
+	Bounds Size
+	// Padding is the padding that was used to pack the rectangles, which is overridden by the
+	// padding in the options of each rectangle when specified.
+	Padding int
+	// Rects contains the packed rectangles.
+	Rects []Rect
+	// Reserved contains areas of the bin that rectangles must not be packed into.
+	Reserved []Rect
+	// Sizes contains the sizes that were given to be packed, which each rectangle is matched to
+	// by its ID. When nil, the IDs and dimensions of the rectangles are not verified, other than
+	// to test for duplicates.
+	Sizes []Size
+	// Aliases maps the ID of each alias to the ID of the rectangle it refers to, so that sizes
+	// with the ID of an alias are not considered missing when the rectangle is packed.
+	Aliases map[int]int
+}
+
+// Validate verifies that a layout upholds the invariants of packing, returning a description of
+// each violation in the order of their kind, or nil if there are none. The following are
+// verified:
+//
+//   - Each rectangle is not empty and lies within the bounds of the bin.
+//   - No two rectangles overlap, and the padding of each rectangle to its right and bottom
+//     edges does not overlap any other rectangle or its padding. Padding that extends beyond the
+//     bounds of the bin is ignored.
+//   - No rectangle or its padding overlaps a reserved area.
+//   - No two rectangles have the same ID.
+//   - When the sizes are specified, each rectangle has the ID of a size and the same dimensions,
+//     which are swapped if it is flipped, and each size has a rectangle.
+//
+// Overlaps are found with a sweep line, so that large layouts can be validated in O(n log n)
+// time when they are mostly correct.
+func Validate(layout Layout) []Violation {
+	violations := validateSpace(&layout)
+	violations = append(violations, validateIDs(layout.Rects, layout.Sizes, layout.Aliases)...)
+	slices.SortStableFunc(violations, func(a, b Violation) int {
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	return violations
+}
+
+// Validate verifies the layout of the packed rectangles, see the Validate function for details.
+// The sizes are those that were given to the packer, or nil to skip verifying the IDs and
+// dimensions of the rectangles. Aliases of the packer are taken into account.
+func (p *Packer) Validate(sizes []Size) []Violation {
+	return Validate(Layout{
+		Bounds:   p.algo.MaxSize(),
+		Padding:  p.Padding,
+		Rects:    p.algo.Rects(),
+		Reserved: p.algo.Reserved(),
+		Sizes:    sizes,
+		Aliases:  p.aliases,
+	})
+}
+
+// Validate verifies the layout of each bin, see the Validate function for details. The IDs are
+// verified across all bins, so that a rectangle packed into more than one bin is a duplicate.
+// The sizes are those that were given to the packer, or nil to skip verifying the IDs and
+// dimensions of the rectangles. Aliases of the packer are taken into account.
+func (p *MultiPacker) Validate(sizes []Size) []Violation {
+	var violations []Violation
+	for _, bin := range p.bins {
+		violations = append(violations, bin.Validate(nil)...)
+	}
+	violations = append(violations, validateIDs(p.Rects(), sizes, p.aliases)...)
+	slices.SortStableFunc(violations, func(a, b Violation) int {
+		return cmp.Compare(a.Kind, b.Kind)
+	})
+	return violations
+}
+
+// validateSpace verifies that the rectangles are within bounds, and that their cells do not
+// overlap each other or a reserved area.
+func validateSpace(layout *Layout) []Violation {
+	var violations []Violation
+	bounds := NewRect(0, 0, layout.Bounds.Width, layout.Bounds.Height)
+
+	// Reserved areas follow the cells of the rectangles, so that an index can refer to either.
+	count := len(layout.Rects)
+	cells := make([]Rect, 0, count+len(layout.Reserved))
+	for _, rect := range layout.Rects {
+		if rect.IsEmpty() || !bounds.ContainsRect(rect) {
+			violations = append(violations, Violation{Kind: ViolationBounds, Rect: rect})
+		}
+		padding := max(rect.Options.padding(layout.Padding), 0)
+		cell := NewRect(rect.X, rect.Y, rect.Width+padding, rect.Height+padding)
+		cells = append(cells, cell.Intersect(bounds))
+	}
+	cells = append(cells, layout.Reserved...)
+
+	overlap := func(i, j int) {
+		if i > j {
+			i, j = j, i
+		}
+		switch {
+		case j >= count:
+			if i < count {
+				violations = append(violations, Violation{Kind: ViolationReserved, Rect: layout.Rects[i], Other: cells[j]})
+			}
+		case layout.Rects[i].Intersects(layout.Rects[j]):
+			violations = append(violations, Violation{Kind: ViolationOverlap, Rect: layout.Rects[i], Other: layout.Rects[j]})
+		default:
+			violations = append(violations, Violation{Kind: ViolationPadding, Rect: layout.Rects[i], Other: layout.Rects[j]})
+		}
+	}
+
+	// Sweep a vertical line from left to right, keeping the cells it crosses ordered from top to
+	// bottom. As the cells crossing the line cannot overlap in a valid layout, only the cells
+	// near the top edge of each new cell need to be tested, which are found by binary search.
+	byLeft := make([]int, 0, len(cells))
+	maxHeight := 0
+	for i := range cells {
+		if !cells[i].IsEmpty() {
+			byLeft = append(byLeft, i)
+			maxHeight = max(maxHeight, cells[i].Height)
+		}
+	}
+	byRight := slices.Clone(byLeft)
+	slices.SortFunc(byLeft, func(a, b int) int { return cmp.Compare(cells[a].X, cells[b].X) })
+	slices.SortFunc(byRight, func(a, b int) int { return cmp.Compare(cells[a].Right(), cells[b].Right()) })
+
+	compareTop := func(a, b int) int {
+		if c := cmp.Compare(cells[a].Y, cells[b].Y); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	}
+
+	var active []int
+	expired := 0
+	for _, i := range byLeft {
+		cell := &cells[i]
+
+		// Every cell that ends at or before the line has already been added, as it starts before
+		// the line too.
+		for ; expired < len(byRight) && cells[byRight[expired]].Right() <= cell.X; expired++ {
+			if index, ok := slices.BinarySearchFunc(active, byRight[expired], compareTop); ok {
+				active = slices.Delete(active, index, index+1)
+			}
+		}
+
+		index, _ := slices.BinarySearchFunc(active, i, compareTop)
+		for j := index; j < len(active) && cells[active[j]].Y < cell.Bottom(); j++ {
+			overlap(i, active[j])
+		}
+		for j := index - 1; j >= 0 && cells[active[j]].Y > cell.Y-maxHeight; j-- {
+			if cells[active[j]].Bottom() > cell.Y {
+				overlap(i, active[j])
+			}
+		}
+		active = slices.Insert(active, index, i)
+	}
+	return violations
+}
+
+// validateIDs verifies that no two rectangles have the same ID, and when the sizes are specified,
+// that each rectangle matches a size and each size has a rectangle.
+func validateIDs(rects []Rect, sizes []Size, aliases map[int]int) []Violation {
+	var violations []Violation
+	packed := make(map[int]int, len(rects))
+	for i, rect := range rects {
+		if first, ok := packed[rect.ID]; ok {
+			violations = append(violations, Violation{Kind: ViolationDuplicate, Rect: rect, Other: rects[first]})
+			continue
+		}
+		packed[rect.ID] = i
+	}
+	if sizes == nil {
+		return violations
+	}
+
+	expected := make(map[int]Size, len(sizes))
+	for _, size := range sizes {
+		if _, ok := expected[size.ID]; !ok {
+			expected[size.ID] = size
+		}
+	}
+
+	for _, rect := range rects {
+		size, ok := expected[rect.ID]
+		if !ok {
+			violations = append(violations, Violation{Kind: ViolationUnknown, Rect: rect})
+			continue
+		}
+
+		width, height := size.Width, size.Height
+		if rect.Flipped {
+			width, height = height, width
+		}
+		if rect.Width != width || rect.Height != height || (rect.Flipped && size.Options.NoFlip) {
+			violations = append(violations, Violation{Kind: ViolationFlipped, Rect: rect, Size: size})
+		}
+	}
+
+	for _, size := range sizes {
+		id := size.ID
+		if target, ok := aliases[id]; ok {
+			id = target
+		}
+		if _, ok := packed[id]; !ok {
+			violations = append(violations, Violation{Kind: ViolationMissing, Rect: Rect{Size: size}})
+		}
+	}
+	return violations
+}
+
+// vim: ts=4